@@ -0,0 +1,161 @@
+package goexpression
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExecute_Assign(t *testing.T) {
+	tests := []struct {
+		name    string
+		exp     string
+		want    any
+		wantErr bool
+	}{
+		{name: "script", exp: "total = price * qty; discount = total > 100 ? 0.1 : 0; total * (1 - discount)", want: 108.0},
+		{name: "assign value", exp: "(n = 5) + n", want: int64(10)},
+		{name: "right associative", exp: "x = y = 3; x + y", want: int64(6)},
+		{name: "trailing semicolon", exp: "n = 1;", want: int64(1)},
+		{name: "compound", exp: "n = 10; n += 5; n -= 1; n *= 2; n /= 4; n %= 4; n", want: int64(3)},
+		{name: "compound bits", exp: "n = 6; n &= 3; n |= 8; n ^= 1; n <<= 2; n >>= 1; n &^= 2; n", want: int64(20)},
+		{name: "compound float", exp: "n = 1; n += 0.5; n", want: 1.5},
+		{name: "compound string", exp: "s = 'a'; s += 'b'; s", want: "ab"},
+		{name: "increment visible", exp: "++price == 31 && price == 31", want: true},
+		{name: "decrement", exp: "--qty; --qty; qty", want: int64(2)},
+		{name: "conditional assign", exp: "n = 0; price > 10 && (n = 1) == 1; n", want: int64(1)},
+		{name: "short-circuit skips assign", exp: "n = 0; price < 10 && (n = 1) == 1; n", want: int64(0)},
+		{name: "reassign param", exp: "price = price * 2; price", want: int64(60)},
+		{name: "type error", exp: "s = 'a'; s -= 1", wantErr: true},
+		{name: "undefined before assign", exp: "m + 1; m = 1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExpression(tt.exp, true, nil)
+			if err != nil {
+				t.Fatalf("NewExpression() error = %v", err)
+			}
+			params := map[string]any{"price": 30, "qty": int64(4)}
+			got, err := e.Execute(params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Execute() got = %#v, want %#v", got, tt.want)
+			}
+			// 默认不修改调用方的 params
+			if !reflect.DeepEqual(params, map[string]any{"price": 30, "qty": int64(4)}) {
+				t.Errorf("Execute() modified params: %v", params)
+			}
+		})
+	}
+}
+
+func TestWithWriteBack(t *testing.T) {
+	e, err := NewExpression("total = price * qty; ++count; total > 100", true, nil, WithWriteBack())
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := map[string]any{"price": 30, "qty": 4, "count": 0}
+	if got, err := e.Bool(params); err != nil || !got {
+		t.Fatalf("Bool() = %v, %v, want true", got, err)
+	}
+	want := map[string]any{"price": 30, "qty": 4, "count": 1, "total": int64(120)}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("params = %v, want %v", params, want)
+	}
+	// 再次执行读取写回的值
+	if _, err := e.Execute(params); err != nil || params["count"] != 2 {
+		t.Errorf("count = %v, %v, want 2", params["count"], err)
+	}
+	// 写回时保持参数原有的数值类型, 无法表示时为执行时的类型
+	e, _ = NewExpression("++a; b += 1; c -= 1; d = d * 2; q = q / 2; n += 0.5", true, nil, WithWriteBack())
+	params = map[string]any{"a": int(1), "b": float32(1.5), "c": uint8(0), "d": testCount(3), "q": 3, "n": json.Number("1")}
+	if _, err := e.Execute(params); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]any{"a": int(2), "b": float32(2.5), "c": int64(-1), "d": testCount(6), "q": int(1), "n": json.Number("1.5")}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("params = %#v, want %#v", params, want)
+	}
+	// params 为 nil 时赋值只在本次执行内可见
+	e, _ = NewExpression("n = 1; n + 1", true, nil, WithWriteBack())
+	if got, err := e.Execute(nil); err != nil || got != int64(2) {
+		t.Errorf("Execute(nil) = %v, %v, want 2", got, err)
+	}
+}
+
+func TestAssign_Schema(t *testing.T) {
+	schema := Schema{Vars: map[string]Type{"price": TypeNumber, "name": TypeString}}
+	tests := []struct {
+		name    string
+		exp     string
+		wantErr string
+	}{
+		{name: "local", exp: "total = price * 2; total > 10"},
+		{name: "local widened", exp: "n = 1; n += 0.5; n > 1"},
+		{name: "declared", exp: "price = price + 1; price > 1"},
+		{name: "wrong type", exp: "price = 'x'; true", wantErr: "type: line 1, column 1 (offset 0): cannot assign string to price (number)"},
+		{name: "local wrong use", exp: "s = 'a'; s && true", wantErr: "type: line 1, column 10 (offset 9): invalid operation string && bool"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewExpression(tt.exp, true, nil, WithSchema(schema))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("NewExpression() error = %v", err)
+				}
+				return
+			}
+			var te *TypeError
+			if !errors.As(err, &te) || err.Error() != tt.wantErr {
+				t.Errorf("NewExpression() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+	if _, ok := schema.Vars["total"]; ok {
+		t.Error("local variables leaked into the schema")
+	}
+}
+
+func TestAssign_Refs(t *testing.T) {
+	tests := []struct {
+		name     string
+		exp      string
+		wantVars []string
+	}{
+		{name: "locals are not inputs", exp: "total = price * qty; total > 100", wantVars: []string{"price", "qty"}},
+		{name: "read before assign", exp: "n = n + 1; n", wantVars: []string{"n"}},
+		{name: "conditional assign", exp: "ok && (n = 1); n", wantVars: []string{"n", "ok"}},
+		{name: "increment", exp: "++n; n > 1", wantVars: []string{"n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExpression(tt.exp, true, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := e.Variables(); !reflect.DeepEqual(got, tt.wantVars) {
+				t.Errorf("Variables() = %v, want %v", got, tt.wantVars)
+			}
+		})
+	}
+}
+
+func TestAssign_PartialEval(t *testing.T) {
+	e, err := NewExpression("total = price * qty; ++qty; total + qty", true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	residual, _, err := e.PartialEval(map[string]any{"qty": 2})
+	if err != nil || residual == nil {
+		t.Fatalf("PartialEval() = %v, %v, want residual", residual, err)
+	}
+	if got := residual.Variables(); !reflect.DeepEqual(got, []string{"price"}) {
+		t.Errorf("residual.Variables() = %v, want [price]", got)
+	}
+	if got, err := residual.Execute(map[string]any{"price": 10}); err != nil || got != int64(23) {
+		t.Errorf("residual.Execute() = %v, %v, want 23", got, err)
+	}
+}
@@ -0,0 +1,227 @@
+package goexpression
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// builtin 内置集合函数, 第一个参数为集合, 第二个参数为 lambda
+// 内置函数名只在后跟 ( 且未注册同名函数时作为函数, 因此仍可以用作变量名
+type builtin uint8
+
+const (
+	builtinAll    builtin = iota // all(list, x => bool) 所有元素都满足条件, 空集合为 true
+	builtinAny                   // any(list, x => bool) 存在满足条件的元素
+	builtinNone                  // none(list, x => bool) 没有满足条件的元素
+	builtinFilter                // filter(list, x => bool) 满足条件的元素组成的集合
+	builtinMap                   // map(list, x => v) 各元素映射的结果组成的集合
+	builtinCount                 // count(list, x => bool) 满足条件的元素个数
+	builtinFind                  // find(list, x => bool) 第一个满足条件的元素, 没有时为 nil
+	builtinReduce                // reduce(list, (acc, x) => v[, init]) 累积计算, 省略 init 时以第一个元素为初始值
+	builtinSortBy                // sortBy(list, x => key) 按 key 升序稳定排序, 不修改原集合
+)
+
+var builtinNames = [...]string{"all", "any", "none", "filter", "map", "count", "find", "reduce", "sortBy"}
+
+var builtins = func() map[string]builtin {
+	ret := make(map[string]builtin, len(builtinNames))
+	for i, name := range builtinNames {
+		ret[name] = builtin(i)
+	}
+	return ret
+}()
+
+func (b builtin) String() string {
+	if int(b) >= len(builtinNames) {
+		return fmt.Sprintf("builtin(%d)", int(b))
+	}
+	return builtinNames[b]
+}
+
+// lambdaParams lambda 的参数个数
+func (b builtin) lambdaParams() int {
+	if b == builtinReduce {
+		return 2
+	}
+	return 1
+}
+
+// predicate lambda 是否为返回 bool 的条件
+func (b builtin) predicate() bool {
+	switch b {
+	case builtinMap, builtinReduce, builtinSortBy:
+		return false
+	}
+	return true
+}
+
+// lambda 编译后的 lambda, 函数体为独立的指令序列
+type lambda struct {
+	params []string
+	body   *program
+}
+
+// toList 将集合参数转换为 []any, nil 视为空集合, 参数或成员访问得到的 []string 等切片逐个转换
+func toList(v any) ([]any, bool) {
+	switch v := v.(type) {
+	case nil:
+		return nil, true
+	case []any:
+		return v, true
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		ret := make([]any, rv.Len())
+		for i := range ret {
+			ret[i] = rv.Index(i).Interface()
+		}
+		return ret, true
+	}
+	return nil, false
+}
+
+// callBuiltin 执行第 pc 条指令调用的内置函数, args 为集合及 reduce 的初始值, 返回的错误已包含源码位置
+// lambda 在以参数创建的内层作用域中执行, 可以读取与赋值外层的变量, 与表达式共享指令数等限制
+func (p *program) callBuiltin(st *execState, fr *frame, pc int, args []slot) (any, error) {
+	var (
+		id  = builtin(p.code[pc].arg)
+		fn  = p.lambdas[p.code[pc].k]
+		err error
+	)
+	list, ok := toList(args[0].value())
+	if !ok {
+		return nil, fmt.Errorf("execute: %v expects a list, got %s", id, typeName(args[0].value()))
+	}
+	// 各次调用复用同一个作用域
+	inner := frame{vars: make(map[string]any, len(fn.params)), parent: fr}
+	call := func(params ...any) (any, error) {
+		for i, name := range fn.params {
+			inner.vars[name] = params[i]
+		}
+		return fn.body.exec(st, &inner)
+	}
+	test := func(item any) (bool, error) {
+		ret, err := call(item)
+		if err != nil {
+			return false, err
+		}
+		ok, isBool := ret.(bool)
+		if !isBool {
+			return false, fmt.Errorf("execute: %v lambda must return bool, got %s", id, typeName(ret))
+		}
+		return ok, nil
+	}
+
+	switch id {
+	case builtinAll, builtinAny, builtinNone:
+		// all 遇到不满足的元素、any 与 none 遇到满足的元素时即可确定结果
+		stop := id != builtinAll
+		for _, item := range list {
+			ok, err := test(item)
+			if err != nil {
+				return nil, err
+			}
+			if ok == stop {
+				return id == builtinAny, nil
+			}
+		}
+		return id != builtinAny, nil
+	case builtinFilter:
+		ret := []any{}
+		for _, item := range list {
+			ok, err := test(item)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				ret = append(ret, item)
+			}
+		}
+		return ret, nil
+	case builtinMap:
+		ret := make([]any, len(list))
+		for i, item := range list {
+			if ret[i], err = call(item); err != nil {
+				return nil, err
+			}
+		}
+		return ret, nil
+	case builtinCount:
+		var n int64
+		for _, item := range list {
+			ok, err := test(item)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				n++
+			}
+		}
+		return n, nil
+	case builtinFind:
+		for _, item := range list {
+			ok, err := test(item)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return item, nil
+			}
+		}
+		return nil, nil
+	case builtinReduce:
+		var acc any
+		if len(args) > 1 {
+			acc = args[1].value()
+		} else {
+			if len(list) == 0 {
+				return nil, fmt.Errorf("execute: reduce of empty list with no initial value")
+			}
+			acc, list = list[0], list[1:]
+		}
+		for _, item := range list {
+			if acc, err = call(acc, item); err != nil {
+				return nil, err
+			}
+		}
+		return acc, nil
+	case builtinSortBy:
+		return sortBy(list, call)
+	}
+	return nil, fmt.Errorf("execute: unknown built-in function %v", id)
+}
+
+// sortBy 按 key 升序稳定排序, key 之间按 < 比较, 无法比较时返回第一个比较错误
+func sortBy(list []any, key func(params ...any) (any, error)) (any, error) {
+	var (
+		keys    = make([]any, len(list))
+		order   = make([]int, len(list))
+		sortErr error
+		err     error
+	)
+	for i, item := range list {
+		if keys[i], err = key(item); err != nil {
+			return nil, err
+		}
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		l, r := keys[order[i]], keys[order[j]]
+		less, err := lssFunc(l, r, nil)
+		if err != nil {
+			if sortErr == nil {
+				sortErr = &EvalError{Op: Lss, Operands: []string{typeName(l), typeName(r)}, Err: err}
+			}
+			return false
+		}
+		return less.(bool)
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+	ret := make([]any, len(list))
+	for i, k := range order {
+		ret[i] = list[k]
+	}
+	return ret, nil
+}
@@ -0,0 +1,174 @@
+package goexpression
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// Cache 编译结果的 LRU 缓存, 可被多个 goroutine 同时使用
+// 函数集合与 Option 不可比较, 因此由 Compiler 绑定, 缓存的 key 为 Compiler、表达式源码与 needCheck
+// 同一个 key 并发未命中时只编译一次, 其余调用等待并共享编译结果; 编译失败的结果不缓存
+type Cache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List // 元素为 *cacheEntry, 表头为最近使用
+	items    map[cacheKey]*list.Element
+	inflight map[cacheKey]*cacheCall
+	nextID   uint64
+	stats    CacheStats
+}
+
+// CacheStats 缓存统计
+type CacheStats struct {
+	Hits      uint64 // 命中次数
+	Misses    uint64 // 未命中次数, 包括等待其他调用编译的次数
+	Compiles  uint64 // 实际编译次数
+	Evictions uint64 // 超出容量被淘汰的次数
+	Size      int    // 当前缓存的表达式数量
+}
+
+// Compiler 绑定函数集合与 Option 的编译器, 编译结果缓存在所属的 Cache 中
+type Compiler struct {
+	cache     *Cache
+	id        uint64
+	functions map[string]Function
+	opts      []Option
+}
+
+type cacheKey struct {
+	compiler  uint64
+	exp       string
+	needCheck bool
+}
+
+type cacheEntry struct {
+	key  cacheKey
+	expr *Expression
+}
+
+// cacheCall 正在进行的编译
+type cacheCall struct {
+	done chan struct{}
+	expr *Expression
+	err  error
+}
+
+// NewCache 创建最多缓存 capacity 个表达式的缓存, capacity <= 0 时不限制数量
+func NewCache(capacity int) *Cache {
+	return &Cache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[cacheKey]*list.Element),
+		inflight: make(map[cacheKey]*cacheCall),
+	}
+}
+
+// Compiler 创建使用该缓存的编译器, functions 在此复制, 之后修改传入的 map 不影响编译器
+// 函数集合或 Option 不同的表达式应使用不同的 Compiler
+func (c *Cache) Compiler(functions map[string]Function, opts ...Option) *Compiler {
+	copied := make(map[string]Function, len(functions))
+	for name, function := range functions {
+		copied[name] = function
+	}
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.mu.Unlock()
+	return &Compiler{cache: c, id: id, functions: copied, opts: append([]Option(nil), opts...)}
+}
+
+// Compile 返回缓存的表达式, 未命中时调用 NewExpression 编译并缓存
+// 编译中的 panic(如 Option 内部 panic)转换为错误返回给本次调用与所有等待的调用, 不会缓存
+func (cp *Compiler) Compile(exp string, needCheck bool) (expr *Expression, err error) {
+	c, key := cp.cache, cacheKey{compiler: cp.id, exp: exp, needCheck: needCheck}
+	c.mu.Lock()
+	if elem, ok := c.items[key]; ok {
+		c.ll.MoveToFront(elem)
+		c.stats.Hits++
+		c.mu.Unlock()
+		return elem.Value.(*cacheEntry).expr, nil
+	}
+	c.stats.Misses++
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.expr, call.err
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.inflight[key] = call
+	c.stats.Compiles++
+	c.mu.Unlock()
+
+	// 在 defer 中唤醒等待的调用, 保证编译 panic 时等待方不会永久阻塞, 且得到同样的错误
+	defer func() {
+		if r := recover(); r != nil {
+			call.expr, call.err = nil, compilePanicError(r)
+			expr, err = nil, call.err
+		}
+		c.mu.Lock()
+		delete(c.inflight, key)
+		if call.err == nil && call.expr != nil {
+			c.add(key, call.expr)
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	call.expr, call.err = NewExpression(exp, needCheck, cp.functions, cp.opts...)
+	if call.err != nil {
+		call.expr = nil
+	}
+	return call.expr, call.err
+}
+
+// compilePanicError 将编译中的 panic 转换为错误, panic 的值为 error 时可用 errors.Is/As 检查
+func compilePanicError(r any) error {
+	if err, ok := r.(error); ok {
+		return fmt.Errorf("compile: panic: %w", err)
+	}
+	return fmt.Errorf("compile: panic: %v", r)
+}
+
+// add 加入缓存并淘汰超出容量的最久未使用的表达式, 调用方需持有锁
+func (c *Cache) add(key cacheKey, expr *Expression) {
+	c.items[key] = c.ll.PushFront(&cacheEntry{key: key, expr: expr})
+	for c.capacity > 0 && c.ll.Len() > c.capacity {
+		c.remove(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.items, elem.Value.(*cacheEntry).key)
+}
+
+// Invalidate 删除所有 Compiler 缓存的源码为 exp 的表达式
+func (c *Cache) Invalidate(exp string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for elem := c.ll.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*cacheEntry).key.exp == exp {
+			c.remove(elem)
+		}
+		elem = next
+	}
+}
+
+// Purge 清空缓存, 统计数据保留
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.items = make(map[cacheKey]*list.Element)
+}
+
+// Stats 返回缓存统计
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Size = c.ll.Len()
+	return stats
+}
@@ -0,0 +1,157 @@
+package goexpression
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	cache := NewCache(2)
+	compiler := cache.Compiler(nil)
+
+	e1, err := compiler.Compile("a > 1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := compiler.Compile("a > 1", true); e != e1 {
+		t.Error("Compile() did not return the cached expression")
+	}
+	if e, _ := compiler.Compile("a > 1", false); e == e1 || e.NeedCheck {
+		t.Error("Compile() with different needCheck returned the same expression")
+	}
+	// 容量为 2, 最久未使用的 a > 1 (needCheck=true) 被淘汰
+	if _, err := compiler.Compile("b > 1", true); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := compiler.Compile("a > 1", true); e == e1 {
+		t.Error("Compile() returned an evicted expression")
+	}
+	want := CacheStats{Hits: 1, Misses: 4, Compiles: 4, Evictions: 2, Size: 2}
+	if got := cache.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+
+	// 编译失败不缓存
+	for i := 0; i < 2; i++ {
+		if _, err := compiler.Compile("a >", true); err == nil {
+			t.Fatal("Compile() error = nil, want syntax error")
+		}
+	}
+	if got := cache.Stats(); got.Compiles != want.Compiles+2 || got.Size != 2 {
+		t.Errorf("Stats() = %+v, want 2 more compiles and size 2", got)
+	}
+
+	cache.Invalidate("a > 1")
+	if got := cache.Stats().Size; got != 1 {
+		t.Errorf("Size after Invalidate = %d, want 1", got)
+	}
+	cache.Purge()
+	if got := cache.Stats().Size; got != 0 {
+		t.Errorf("Size after Purge = %d, want 0", got)
+	}
+}
+
+func TestCache_Compilers(t *testing.T) {
+	cache := NewCache(0)
+	functions := map[string]Function{"one": func(params ...any) (any, error) { return 1, nil }}
+	withOne := cache.Compiler(functions)
+	withoutOne := cache.Compiler(nil)
+	// 修改传入的函数 map 不影响已创建的编译器
+	delete(functions, "one")
+
+	e, err := withOne.Compile("one() == 1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := e.Bool(nil); err != nil || !got {
+		t.Errorf("Bool() = %v, %v, want true", got, err)
+	}
+	if _, err := withoutOne.Compile("one() == 1", true); err == nil {
+		t.Error("Compile() without one error = nil, want unknown function")
+	}
+	limited := cache.Compiler(nil, WithLimits(Limits{MaxLength: 3}))
+	if _, err := limited.Compile("a > 1", true); err == nil {
+		t.Error("Compile() with limits error = nil, want *LimitError")
+	}
+}
+
+func TestCache_SingleFlight(t *testing.T) {
+	cache := NewCache(10)
+	compiler := cache.Compiler(nil)
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+		exprs = make([]*Expression, 50)
+	)
+	for i := range exprs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			exprs[i], _ = compiler.Compile("a + b * 2 > 10 && s in ['x', 'y']", true)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	for _, e := range exprs {
+		if e == nil || e != exprs[0] {
+			t.Fatal("concurrent Compile() returned different expressions")
+		}
+	}
+	if got := cache.Stats(); got.Compiles != 1 || got.Hits+got.Misses != 50 {
+		t.Errorf("Stats() = %+v, want 1 compile and 50 lookups", got)
+	}
+}
+
+func TestCache_CompilePanic(t *testing.T) {
+	var (
+		release = make(chan struct{})
+		boom    = errors.New("boom")
+		once    sync.Once
+	)
+	// 第一次编译阻塞到所有调用都在等待后 panic, 之后的编译正常
+	panicking := Option(func(*config) {
+		panicked := false
+		once.Do(func() {
+			<-release
+			panicked = true
+		})
+		if panicked {
+			panic(boom)
+		}
+	})
+	cache := NewCache(10)
+	compiler := cache.Compiler(nil, panicking)
+	const n = 20
+	var (
+		wg    sync.WaitGroup
+		exprs = make([]*Expression, n)
+		errs  = make([]error, n)
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			exprs[i], errs[i] = compiler.Compile("a + 1", true)
+		}(i)
+	}
+	for cache.Stats().Misses < n {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+	for i := 0; i < n; i++ {
+		if exprs[i] != nil || !errors.Is(errs[i], boom) {
+			t.Fatalf("Compile() = %v, %v, want error wrapping boom", exprs[i], errs[i])
+		}
+	}
+	// panic 的结果不缓存, 再次编译成功
+	if e, err := compiler.Compile("a + 1", true); e == nil || err != nil {
+		t.Errorf("Compile() after panic = %v, %v", e, err)
+	}
+	if got := cache.Stats(); got.Compiles != 2 {
+		t.Errorf("Stats().Compiles = %d, want 2", got.Compiles)
+	}
+}
@@ -0,0 +1,385 @@
+package goexpression
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// opcode 指令类型
+type opcode uint8
+
+const (
+	opPush        opcode = iota // 压入常量 consts[arg]
+	opLoad                      // 压入变量 params[names[arg]], argc 为 1 时变量不存在的结果为 nil
+	opCall                      // 弹出 argc 个参数, 调用 funcs[arg]
+	opList                      // 弹出 arg 个值, 压入与 commaFunc 逐个连接等价的 []any
+	opUnary                     // 弹出一个值, 压入一元操作符 Operator(arg) 结果
+	opBinary                    // 弹出两个值, 压入二元操作符 Operator(arg) 结果, argc 为 1 时不使用数值的快速路径
+	opBinaryConst               // 右操作数为常量 consts[k] 的 opBinary, 弹出一个值
+	opIndex                     // 弹出 key 与对象, 压入成员访问/索引结果, argc 为 1 时成员不存在的结果为 nil
+	opIndexConst                // key 为常量 consts[k] 的 opIndex, 如 a.b
+	opJumpFalse                 // 栈顶为 false 时跳转到 arg, 栈顶保留作为结果; argc 为 1 时未跳转则弹出栈顶
+	opJumpTrue                  // 栈顶为 true 时跳转到 arg, 栈顶保留作为结果; argc 为 1 时未跳转则弹出栈顶
+	opJump                      // 跳转到 arg
+	opBranch                    // 弹出三元表达式的条件, 为 false 时跳转到 arg, 不为 bool 时报错
+	opStore                     // 将栈顶赋值给变量 names[arg], 栈顶保留作为结果
+	opPop                       // 弹出栈顶, 用于丢弃 ';' 前语句的结果
+	opBuiltin                   // 弹出 argc 个参数, 以 lambdas[k] 调用内置函数 builtin(arg)
+	opJumpNil                   // 栈顶为 nil 时跳转到 arg, 栈顶保留作为结果, 用于 ?.
+	opJumpNotNil                // 栈顶不为 nil 时跳转到 arg, 栈顶保留作为结果, 用于 ??
+)
+
+// instr 一条指令
+type instr struct {
+	op   opcode
+	argc uint8
+	arg  int32
+	k    int32
+}
+
+// program 表达式编译结果, 一段扁平的指令序列, 由 vm 非递归执行
+// 编译完成后只读, 可被多个 goroutine 同时执行
+type program struct {
+	src       string
+	code      []instr
+	spans     []Span // 各指令对应的源码区间, 用于报告执行错误的位置
+	consts    []slot
+	names     []string
+	funcs     []ContextFunction
+	lambdas   []*lambda
+	maxStack  int
+	limits    Limits
+	writeBack bool            // 赋值写回调用方传入的 params
+	lenient   bool            // 不存在的变量为 nil
+	decimal   *decimalContext // 精确小数模式, 为 nil 时未开启
+	cacheVars bool            // 一次执行中变量的值不会改变, 每个变量只需读取一次, 见 canCacheVars
+}
+
+// compiler 将 astNode 编译为 program
+type compiler struct {
+	prog  *program
+	depth int // 当前栈深度
+}
+
+// compile 编译抽象语法树, src 为表达式源码, cfg 中的资源限制等在执行时生效
+func compile(root *astNode, src string, cfg *config) (*program, error) {
+	c := &compiler{prog: &program{src: src, limits: cfg.limits, writeBack: cfg.writeBack, lenient: cfg.lenient, decimal: cfg.decimal}}
+	if err := c.compileNode(root); err != nil {
+		return nil, err
+	}
+	c.prog.cacheVars = canCacheVars(c.prog)
+	return c.prog, nil
+}
+
+func (c *compiler) emit(node *astNode, op opcode, arg int, argc int) int {
+	var span Span
+	if node != nil {
+		span = node.span
+	}
+	c.prog.code = append(c.prog.code, instr{op: op, arg: int32(arg), argc: uint8(argc)})
+	c.prog.spans = append(c.prog.spans, span)
+	return len(c.prog.code) - 1
+}
+
+// patch 将跳转指令的目标设置为下一条指令
+func (c *compiler) patch(at int) {
+	c.prog.code[at].arg = int32(len(c.prog.code))
+}
+
+func (c *compiler) push() {
+	c.depth++
+	if c.depth > c.prog.maxStack {
+		c.prog.maxStack = c.depth
+	}
+}
+
+func (c *compiler) pop(n int) {
+	c.depth -= n
+}
+
+func (c *compiler) compileNode(node *astNode) error {
+	// 空节点, 如 [] 或一元操作符的右子节点, 执行结果为 nil
+	if node == nil {
+		c.emit(node, opPush, c.addConst(nil), 0)
+		c.push()
+		return nil
+	}
+
+	switch node.kind {
+	case litNode:
+		c.emit(node, opPush, c.addConst(node.value), 0)
+		c.push()
+	case varNode:
+		c.emit(node, opLoad, c.addName(node.value.(string)), 0)
+		c.push()
+	case funcNode:
+		c.prog.funcs = append(c.prog.funcs, node.value.(ContextFunction))
+		index := len(c.prog.funcs) - 1
+		if node.right == nil {
+			c.emit(node, opCall, index, 0)
+			c.push()
+			return nil
+		}
+		// 参数按位置传递, f([1, 2], 3) 的参数为 [1, 2] 与 3
+		args := commaItems(node.right)
+		for _, arg := range args {
+			if err := c.compileNode(arg); err != nil {
+				return err
+			}
+		}
+		c.emit(node, opCall, index, len(args))
+		c.pop(len(args) - 1)
+	case listNode:
+		// 展开后一次性构造集合
+		items := commaItems(node.left)
+		for _, item := range items {
+			if err := c.compileNode(item); err != nil {
+				return err
+			}
+		}
+		c.emit(node, opList, len(items), 0)
+		c.pop(len(items) - 1)
+	case indexNode:
+		return c.compileChain(node, false)
+	case opNode:
+		return c.compileOp(node)
+	case assignNode:
+		value := node.right
+		if node.op != NotOperator { // a += 1 按 a = a + 1 计算
+			value = &astNode{kind: opNode, op: node.op, left: node.left, right: node.right, span: node.span}
+		}
+		if err := c.compileNode(value); err != nil {
+			return err
+		}
+		c.emit(node, opStore, c.addName(node.left.value.(string)), 0)
+	case seqNode:
+		if err := c.compileNode(node.left); err != nil {
+			return err
+		}
+		c.emit(node.left, opPop, 0, 0)
+		c.pop(1)
+		return c.compileNode(node.right)
+	case builtinNode:
+		return c.compileBuiltin(node)
+	case ternaryNode:
+		return c.compileTernary(node)
+	default:
+		return fmt.Errorf("compile: unknown node kind %d", node.kind)
+	}
+	return nil
+}
+
+// binaryArgc 精确小数模式下整数的加减乘除需按 decimalContext.operate 计算, 不使用快速路径
+func (c *compiler) binaryArgc(op Operator) int {
+	if c.prog.decimal != nil && decimalArith(op) {
+		return 1
+	}
+	return 0
+}
+
+func (c *compiler) compileOp(node *astNode) error {
+	if !node.op.IsBinaryOperator() {
+		if err := c.compileNode(node.left); err != nil {
+			return err
+		}
+		c.emit(node, opUnary, int(node.op), 0)
+		// 变量的 ++ 与 -- 将结果写回变量
+		if (node.op == AddAdd || node.op == SubSub) && node.left != nil && node.left.kind == varNode {
+			c.emit(node, opStore, c.addName(node.left.value.(string)), 0)
+		}
+		return nil
+	}
+
+	if node.op == Coalesce {
+		return c.compileCoalesce(node)
+	}
+	// 左边结果可能可以直接决定结果的, 生成跳转指令短路右子树
+	var jump = -1
+	if err := c.compileNode(node.left); err != nil {
+		return err
+	}
+	switch node.op {
+	case AndAnd:
+		jump = c.emit(node, opJumpFalse, 0, 0)
+	case OrOr:
+		jump = c.emit(node, opJumpTrue, 0, 0)
+	default:
+	}
+	// 两边结果均为 bool 时未短路的结果就是右边的结果, 省去 && 与 || 的计算
+	if jump >= 0 && isBoolNode(node.left) && isBoolNode(node.right) {
+		c.prog.code[jump].argc = 1
+		c.pop(1)
+		if err := c.compileNode(node.right); err != nil {
+			return err
+		}
+		c.patch(jump)
+		return nil
+	}
+	if jump < 0 && node.right != nil && node.right.kind == litNode {
+		value := node.right.value
+		// 字面量的正则表达式在编译时预编译, 错误的正则表达式使 NewExpression 失败
+		if s, ok := value.(string); ok && (node.op == Match || node.op == NotMatch) {
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return &SyntaxError{ErrorPos: newErrorPos(c.prog.src, node.right.span), Msg: fmt.Sprintf("invalid regular expression %q: %v", s, err)}
+			}
+			value = re
+		}
+		at := c.emit(node, opBinaryConst, int(node.op), c.binaryArgc(node.op))
+		c.prog.code[at].k = int32(c.addConst(value))
+		return nil
+	}
+	if err := c.compileNode(node.right); err != nil {
+		return err
+	}
+	c.emit(node, opBinary, int(node.op), c.binaryArgc(node.op))
+	c.pop(1)
+	if jump >= 0 {
+		c.patch(jump)
+	}
+	return nil
+}
+
+// compileCoalesce a ?? b 中 a 的变量与成员不存在时视为 nil, a 不为 nil 时不计算 b
+func (c *compiler) compileCoalesce(node *astNode) error {
+	if err := c.compileChain(node.left, true); err != nil {
+		return err
+	}
+	jump := c.emit(node, opJumpNotNil, 0, 0)
+	c.emit(node, opPop, 0, 0)
+	c.pop(1)
+	if err := c.compileNode(node.right); err != nil {
+		return err
+	}
+	c.patch(jump)
+	return nil
+}
+
+// compileChain 编译成员访问链, 如 a?.b.c[0], 可选链的对象为 nil 时跳转到链的末尾, 结果为 nil
+// lenient 时链中的变量与成员不存在的结果为 nil
+func (c *compiler) compileChain(node *astNode, lenient bool) error {
+	var jumps []int
+	if err := c.compileLink(node, lenient, &jumps); err != nil {
+		return err
+	}
+	for _, jump := range jumps {
+		c.patch(jump)
+	}
+	return nil
+}
+
+func (c *compiler) compileLink(node *astNode, lenient bool, jumps *[]int) error {
+	if lenient && node != nil && node.kind == varNode {
+		c.emit(node, opLoad, c.addName(node.value.(string)), 1)
+		c.push()
+		return nil
+	}
+	if node == nil || node.kind != indexNode {
+		return c.compileNode(node)
+	}
+	if err := c.compileLink(node.left, lenient, jumps); err != nil {
+		return err
+	}
+	optional := node.value == true
+	if optional {
+		*jumps = append(*jumps, c.emit(node, opJumpNil, 0, 0))
+	}
+	argc := 0
+	if lenient || optional {
+		argc = 1
+	}
+	if node.right != nil && node.right.kind == litNode {
+		at := c.emit(node, opIndexConst, 0, argc)
+		c.prog.code[at].k = int32(c.addConst(node.right.value))
+		return nil
+	}
+	if err := c.compileNode(node.right); err != nil {
+		return err
+	}
+	c.emit(node, opIndex, 0, argc)
+	c.pop(1)
+	return nil
+}
+
+// compileTernary 条件为 true 时只执行 then 分支, 否则跳过 then 分支只执行 else 分支
+func (c *compiler) compileTernary(node *astNode) error {
+	then, els := ternaryBranches(node)
+	if err := c.compileNode(node.left); err != nil {
+		return err
+	}
+	branch := c.emit(node, opBranch, 0, 0)
+	c.pop(1)
+	if err := c.compileNode(then); err != nil {
+		return err
+	}
+	jump := c.emit(node, opJump, 0, 0)
+	c.patch(branch)
+	c.pop(1) // 两个分支只有一个压栈
+	if err := c.compileNode(els); err != nil {
+		return err
+	}
+	c.patch(jump)
+	return nil
+}
+
+// compileBuiltin 集合与 reduce 的初始值压栈, lambda 的函数体编译为独立的指令序列
+func (c *compiler) compileBuiltin(node *astNode) error {
+	var (
+		args = builtinArgs(node)
+		argc int
+		fn   *lambda
+	)
+	for _, arg := range args {
+		if arg.kind == lambdaNode {
+			body := &compiler{prog: &program{src: c.prog.src, limits: c.prog.limits, writeBack: c.prog.writeBack, lenient: c.prog.lenient, decimal: c.prog.decimal}}
+			if err := body.compileNode(arg.left); err != nil {
+				return err
+			}
+			body.prog.cacheVars = canCacheVars(body.prog)
+			fn = &lambda{params: arg.value.([]string), body: body.prog}
+			continue
+		}
+		if err := c.compileNode(arg); err != nil {
+			return err
+		}
+		argc++
+	}
+	if fn == nil || argc == 0 {
+		return fmt.Errorf("compile: %s needs a list and a lambda", node.name)
+	}
+	c.prog.lambdas = append(c.prog.lambdas, fn)
+	at := c.emit(node, opBuiltin, int(node.value.(builtin)), argc)
+	c.prog.code[at].k = int32(len(c.prog.lambdas) - 1)
+	c.pop(argc - 1)
+	return nil
+}
+
+// canCacheVars 没有赋值、函数调用与 lambda 时执行中变量的值不会改变
+// 读取过的变量保存在栈数组 maxStack 之后的空闲位置, 因此要求两者之和不超过 smallStackSize
+func canCacheVars(prog *program) bool {
+	if prog.maxStack+len(prog.names) > smallStackSize {
+		return false
+	}
+	for _, ins := range prog.code {
+		switch ins.op {
+		case opStore, opCall, opBuiltin:
+			return false
+		}
+	}
+	return true
+}
+
+func (c *compiler) addConst(v any) int {
+	c.prog.consts = append(c.prog.consts, slotOf(v))
+	return len(c.prog.consts) - 1
+}
+
+func (c *compiler) addName(name string) int {
+	for i, n := range c.prog.names {
+		if n == name {
+			return i
+		}
+	}
+	c.prog.names = append(c.prog.names, name)
+	return len(c.prog.names) - 1
+}
@@ -0,0 +1,112 @@
+package goexpression
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type ctxKey struct{}
+
+func TestExecuteContext(t *testing.T) {
+	var calls int
+	functions := map[string]Function{
+		"slow": func(params ...any) (any, error) {
+			calls++
+			time.Sleep(20 * time.Millisecond)
+			return true, nil
+		},
+		"user": func(params ...any) (any, error) { return "plain", nil },
+	}
+	contextFunctions := map[string]ContextFunction{
+		"user": func(ctx context.Context, params ...any) (any, error) {
+			return ctx.Value(ctxKey{}), nil
+		},
+		"wait": func(ctx context.Context, params ...any) (any, error) {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Second):
+				return true, nil
+			}
+		},
+	}
+	valueCtx := context.WithValue(context.Background(), ctxKey{}, "alice")
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name      string
+		exp       string
+		ctx       func() (context.Context, context.CancelFunc)
+		want      any
+		wantErr   error
+		wantCalls int
+	}{
+		{
+			name: "context function gets ctx", exp: "user() == 'alice'",
+			ctx:  func() (context.Context, context.CancelFunc) { return valueCtx, func() {} },
+			want: true,
+		},
+		{
+			name: "canceled before start", exp: "a > 1",
+			ctx:     func() (context.Context, context.CancelFunc) { return canceled, func() {} },
+			wantErr: context.Canceled,
+		},
+		{
+			name: "context function honors deadline", exp: "a > 1 && wait()",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 10*time.Millisecond)
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name: "checked between nodes", exp: "slow() && slow() && slow()",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 10*time.Millisecond)
+			},
+			wantErr:   context.DeadlineExceeded,
+			wantCalls: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			e, err := NewExpression(tt.exp, true, functions, WithContextFunctions(contextFunctions))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			got, err := e.ExecuteContext(ctx, map[string]any{"a": 2})
+			if tt.wantErr != nil {
+				var ee *EvalError
+				if !errors.Is(err, tt.wantErr) || !errors.As(err, &ee) {
+					t.Fatalf("ExecuteContext() error = %v, want *EvalError wrapping %v", err, tt.wantErr)
+				}
+				if calls != tt.wantCalls {
+					t.Errorf("function called %d times, want %d", calls, tt.wantCalls)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ExecuteContext() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecute_ContextFunction(t *testing.T) {
+	e, err := NewExpression("hasCtx()", true, nil, WithContextFunctions(map[string]ContextFunction{
+		"hasCtx": func(ctx context.Context, params ...any) (any, error) {
+			return ctx == context.Background(), nil
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := e.Bool(nil); err != nil || !got {
+		t.Errorf("Bool() = %v, %v, want true", got, err)
+	}
+}
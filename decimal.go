@@ -0,0 +1,359 @@
+package goexpression
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Decimal 任意精度的十进制小数, 值为 coef × 10^-scale, 零值为 0
+// 开启 WithDecimal 后含小数点的数值字面量与 float64 参数转换为 Decimal, 加减乘、取余与比较的结果是精确的
+// Decimal 不可变, 可以作为参数传入, 也可以通过 Expression.Decimal 取得结果
+type Decimal struct {
+	coef  *big.Int // nil 为 0
+	scale int32    // 小数位数, 不小于 0
+}
+
+// Rounding 舍入方式
+type Rounding int
+
+const (
+	RoundHalfUp   Rounding = iota // 四舍五入, .5 远离 0
+	RoundHalfEven                 // 四舍六入五取偶(银行家舍入)
+	RoundDown                     // 向 0 截断
+	RoundUp                       // 远离 0
+	RoundFloor                    // 向负无穷
+	RoundCeiling                  // 向正无穷
+)
+
+// decimalContext 精确小数模式下除法结果保留的小数位数与舍入方式
+type decimalContext struct {
+	scale    int32
+	rounding Rounding
+}
+
+// defaultDecimalContext 未开启 WithDecimal 时 Decimal 参数之间相除使用的配置
+var defaultDecimalContext = &decimalContext{scale: 16, rounding: RoundHalfUp}
+
+var (
+	errDecimalDivideByZero = errors.New("execute: decimal divide by zero")
+	bigTen                 = big.NewInt(10)
+)
+
+// NewDecimal 值为 coef × 10^-scale 的 Decimal, eg: NewDecimal(1030, 2) 为 10.30
+func NewDecimal(coef int64, scale int32) Decimal {
+	return newDecimal(big.NewInt(coef), int64(scale))
+}
+
+// newDecimal scale 小于 0 时转换为整数
+func newDecimal(coef *big.Int, scale int64) Decimal {
+	if scale < 0 {
+		coef = new(big.Int).Mul(coef, pow10(-scale))
+		scale = 0
+	}
+	return Decimal{coef: coef, scale: int32(scale)}
+}
+
+// ParseDecimal 解析十进制小数, 支持正负号与指数, eg: 10.30、-.5、1e-3
+func ParseDecimal(s string) (Decimal, error) {
+	mantissa, exp := s, int64(0)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+		}
+		mantissa, exp = s[:i], e
+	}
+	digits := mantissa
+	if digits != "" && (digits[0] == '+' || digits[0] == '-') {
+		digits = digits[1:]
+	}
+	intPart, fracPart, _ := strings.Cut(digits, ".")
+	if intPart+fracPart == "" || strings.Trim(intPart+fracPart, "0123456789") != "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	coef, _ := new(big.Int).SetString(intPart+fracPart, 10)
+	if mantissa[0] == '-' {
+		coef.Neg(coef)
+	}
+	scale := int64(len(fracPart)) - exp
+	if scale > maxDecimalScale || scale < -maxDecimalScale {
+		return Decimal{}, fmt.Errorf("invalid decimal %q: exponent out of range", s)
+	}
+	return newDecimal(coef, scale), nil
+}
+
+// DecimalFromFloat 按 float64 的最短十进制表示转换, eg: 0.1 转换为 0.1 而不是 0.1000000000000000055...
+// NaN 与 ±Inf 返回 false
+func DecimalFromFloat(f float64) (Decimal, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}, false
+	}
+	d, err := ParseDecimal(strconv.FormatFloat(f, 'g', -1, 64))
+	return d, err == nil
+}
+
+func (d Decimal) int() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// String 不带指数的十进制表示, 保留小数位数, eg: 1.10 * 2 为 2.20
+func (d Decimal) String() string {
+	coef := d.int()
+	digits := new(big.Int).Abs(coef).String()
+	if d.scale > 0 {
+		if pad := int(d.scale) + 1 - len(digits); pad > 0 {
+			digits = strings.Repeat("0", pad) + digits
+		}
+		digits = digits[:len(digits)-int(d.scale)] + "." + digits[len(digits)-int(d.scale):]
+	}
+	if coef.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// Float64 最接近的 float64
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// Int64 向 0 截断为整数, 超出 int64 范围时返回 false
+func (d Decimal) Int64() (int64, bool) {
+	i := d.int()
+	if d.scale > 0 {
+		i = new(big.Int).Quo(i, pow10(int64(d.scale)))
+	}
+	return i.Int64(), i.IsInt64()
+}
+
+// Sign 返回 -1、0、1
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+// Cmp 比较大小, 返回 -1、0、1, 与小数位数无关, eg: 1.0 与 1.00 相等
+func (d Decimal) Cmp(other Decimal) int {
+	l, r, _ := align(d, other)
+	return l.Cmp(r)
+}
+
+// Round 按 mode 舍入到 scale 位小数, 小数位数不超过 scale 时原样返回
+func (d Decimal) Round(scale int32, mode Rounding) Decimal {
+	if scale < 0 {
+		scale = 0
+	}
+	if d.scale <= scale {
+		return d
+	}
+	return Decimal{coef: roundQuo(d.int(), pow10(int64(d.scale-scale)), mode), scale: scale}
+}
+
+func (d Decimal) add(other Decimal) Decimal {
+	l, r, scale := align(d, other)
+	return Decimal{coef: new(big.Int).Add(l, r), scale: scale}
+}
+
+func (d Decimal) sub(other Decimal) Decimal {
+	l, r, scale := align(d, other)
+	return Decimal{coef: new(big.Int).Sub(l, r), scale: scale}
+}
+
+func (d Decimal) mul(other Decimal) Decimal {
+	return newDecimal(new(big.Int).Mul(d.int(), other.int()), int64(d.scale)+int64(other.scale))
+}
+
+func (d Decimal) neg() Decimal {
+	return Decimal{coef: new(big.Int).Neg(d.int()), scale: d.scale}
+}
+
+// rem 取余, 结果与被除数同号, 与 math.Mod 一致
+func (d Decimal) rem(other Decimal) (Decimal, error) {
+	l, r, scale := align(d, other)
+	if r.Sign() == 0 {
+		return Decimal{}, errDecimalDivideByZero
+	}
+	return Decimal{coef: new(big.Int).Rem(l, r), scale: scale}, nil
+}
+
+// quo 相除, 结果按 ctx 保留小数位数并舍入
+func (d Decimal) quo(other Decimal, ctx *decimalContext) (Decimal, error) {
+	if other.Sign() == 0 {
+		return Decimal{}, errDecimalDivideByZero
+	}
+	// d / other = d.coef × 10^(other.scale - d.scale) / other.coef, 再放大 10^ctx.scale
+	num, den := new(big.Int).Set(d.int()), new(big.Int).Set(other.int())
+	if shift := int64(ctx.scale) + int64(other.scale) - int64(d.scale); shift >= 0 {
+		num.Mul(num, pow10(shift))
+	} else {
+		den.Mul(den, pow10(-shift))
+	}
+	return Decimal{coef: roundQuo(num, den, ctx.rounding), scale: ctx.scale}, nil
+}
+
+// align 将两个 Decimal 对齐到相同的小数位数, 返回对齐后的系数与小数位数
+func align(l, r Decimal) (*big.Int, *big.Int, int32) {
+	switch {
+	case l.scale < r.scale:
+		return new(big.Int).Mul(l.int(), pow10(int64(r.scale-l.scale))), r.int(), r.scale
+	case l.scale > r.scale:
+		return l.int(), new(big.Int).Mul(r.int(), pow10(int64(l.scale-r.scale))), l.scale
+	}
+	return l.int(), r.int(), l.scale
+}
+
+// roundQuo num / den 按 mode 舍入为整数
+func roundQuo(num, den *big.Int, mode Rounding) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	// 商的符号, 截断后的商为 0 时由余数与除数决定
+	sign := r.Sign() * den.Sign()
+	up := false
+	switch mode {
+	case RoundUp:
+		up = true
+	case RoundFloor:
+		up = sign < 0
+	case RoundCeiling:
+		up = sign > 0
+	case RoundHalfUp, RoundHalfEven:
+		half := new(big.Int).Abs(r)
+		switch half.Lsh(half, 1).Cmp(new(big.Int).Abs(den)) {
+		case 1:
+			up = true
+		case 0:
+			up = mode == RoundHalfUp || q.Bit(0) == 1
+		}
+	}
+	if up {
+		q.Add(q, big.NewInt(int64(sign)))
+	}
+	return q
+}
+
+func pow10(n int64) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(n), nil)
+}
+
+// toDecimal 数值转换为 Decimal, float64 按最短十进制表示转换
+func toDecimal(v any) (Decimal, bool) {
+	switch n := v.(type) {
+	case Decimal:
+		return n, true
+	case int64:
+		return Decimal{coef: big.NewInt(n)}, true
+	case float64:
+		return DecimalFromFloat(n)
+	}
+	if n, ok := asNumber(v); ok {
+		return toDecimal(n)
+	}
+	return Decimal{}, false
+}
+
+// decimalOperands 至少一个操作数为 Decimal 且另一个为数值时, 转换为 Decimal 返回
+func decimalOperands(left, right any) (Decimal, Decimal, bool) {
+	_, lok := left.(Decimal)
+	_, rok := right.(Decimal)
+	if !lok && !rok {
+		return Decimal{}, Decimal{}, false
+	}
+	l, ok := toDecimal(left)
+	if !ok {
+		return Decimal{}, Decimal{}, false
+	}
+	r, ok := toDecimal(right)
+	return l, r, ok
+}
+
+// div 操作数含 Decimal 时按 ctx 相除, 否则与 divFunc 相同
+func (ctx *decimalContext) div(left, right any) (any, error) {
+	if l, r, ok := decimalOperands(left, right); ok {
+		return l.quo(r, ctx)
+	}
+	return divFunc(left, right, nil)
+}
+
+// operate 精确小数模式下计算操作符 op, 整数相除同样按 ctx 保留小数位数并舍入, 如 10 / 3 为 3.3333;
+// 整数的加减乘、乘方、取负与 ++、-- 溢出 int64 时结果为精确的 Decimal, 而不是回绕; 其余与 opFuncArray 相同
+func (ctx *decimalContext) operate(op Operator, left, right any, params map[string]any) (any, error) {
+	if l, ok := left.(int64); ok && !op.IsBinaryOperator() {
+		switch {
+		case op == Minus && l == math.MinInt64:
+			return Decimal{coef: big.NewInt(l)}.neg(), nil
+		case op == AddAdd && l == math.MaxInt64:
+			return Decimal{coef: big.NewInt(l)}.add(NewDecimal(1, 0)), nil
+		case op == SubSub && l == math.MinInt64:
+			return Decimal{coef: big.NewInt(l)}.sub(NewDecimal(1, 0)), nil
+		}
+	}
+	if l, r, ok := intOperands(left, right); ok && op.IsBinaryOperator() {
+		ld, rd := Decimal{coef: big.NewInt(l)}, Decimal{coef: big.NewInt(r)}
+		switch op {
+		case Add:
+			if ret := l + r; (ret > l) == (r > 0) {
+				return ret, nil
+			}
+			return ld.add(rd), nil
+		case Sub:
+			if ret := l - r; (ret < l) == (r > 0) {
+				return ret, nil
+			}
+			return ld.sub(rd), nil
+		case Mul:
+			// MinInt64 / -1 仍为 MinInt64, 需要单独判断
+			if ret := l * r; l == 0 || (ret/l == r && !(l == -1 && r == math.MinInt64)) {
+				return ret, nil
+			}
+			return ld.mul(rd), nil
+		case Div:
+			return ld.quo(rd, ctx)
+		case Exponent:
+			if r >= 0 {
+				// 结果在 int64 范围内时与普通模式一样为 int64
+				ret := decimalPow(ld, rd)
+				if d, ok := ret.(Decimal); ok && d.scale == 0 && d.int().IsInt64() {
+					return d.int().Int64(), nil
+				}
+				return ret, nil
+			}
+		}
+	}
+	if op == Div {
+		return ctx.div(left, right)
+	}
+	return opFuncArray[op](left, right, params)
+}
+
+// decimalArith 精确小数模式下整数结果可能不同于 int64 运算的双目操作符, 执行时不使用整数的快速路径
+func decimalArith(op Operator) bool {
+	return op == Add || op == Sub || op == Mul || op == Div
+}
+
+// decimalPow 非负整数次幂精确计算, 其余按 float64 计算后转换为 Decimal
+func decimalPow(base, exp Decimal) any {
+	e, ok := exp.Int64()
+	if ok && exp.Cmp(Decimal{coef: big.NewInt(e)}) == 0 && e >= 0 && e <= maxDecimalPow && int64(base.scale)*e <= maxDecimalScale {
+		coef := new(big.Int).Exp(base.int(), big.NewInt(e), nil)
+		return newDecimal(coef, int64(base.scale)*e)
+	}
+	f := math.Pow(base.Float64(), exp.Float64())
+	if d, ok := DecimalFromFloat(f); ok {
+		return d
+	}
+	return f
+}
+
+const (
+	maxDecimalPow   = 1024  // 精确计算的最大指数, 避免系数过大
+	maxDecimalScale = 10000 // 解析与乘方结果的最大小数位数
+)
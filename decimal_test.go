@@ -0,0 +1,192 @@
+package goexpression
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWithDecimal(t *testing.T) {
+	functions := map[string]Function{
+		"tenth": func(params ...any) (any, error) { return 0.1, nil },
+	}
+	params := map[string]any{
+		"a":     0.1,
+		"b":     0.2,
+		"price": 19.99,
+		"qty":   3,
+		"x":     int64(10),
+		"n":     json.Number("0.3"),
+		"d":     NewDecimal(105, 2),
+		"order": map[string]any{"fee": 0.3},
+		"items": []any{0.1, 0.2, 0.3},
+	}
+	tests := []struct {
+		name    string
+		exp     string
+		want    any
+		wantErr string
+	}{
+		{name: "literal", exp: "0.1 + 0.2 == 0.3", want: true},
+		{name: "float params", exp: "a + b == 0.3 && a + b - 0.3 == 0", want: true},
+		{name: "result", exp: "a + b", want: "0.3"},
+		{name: "keeps scale", exp: "1.10 * 2", want: "2.20"},
+		{name: "money", exp: "price * qty", want: "59.97"},
+		{name: "int and decimal", exp: "1 + 0.5", want: "1.5"},
+		{name: "int division", exp: "10 / 3", want: "3.33"},
+		{name: "int param division", exp: "x / 3", want: "3.33"},
+		{name: "int param division half up", exp: "qty / 8", want: "0.38"},
+		{name: "exact int division", exp: "x / 2", want: "5.00"},
+		{name: "int stays int", exp: "x * 3 + x % 3 - 1", want: int64(30)},
+		{name: "int power", exp: "2 ** 10", want: int64(1024)},
+		{name: "add overflow", exp: "9223372036854775807 + 1", want: "9223372036854775808"},
+		{name: "param add overflow", exp: "x + 9223372036854775807", want: "9223372036854775817"},
+		{name: "sub overflow", exp: "-x - 9223372036854775807", want: "-9223372036854775817"},
+		{name: "mul overflow", exp: "x * 9223372036854775807", want: "92233720368547758070"},
+		{name: "mul min overflow", exp: "m = -9223372036854775807 - 1; m * -1", want: "9223372036854775808"},
+		{name: "power overflow", exp: "2 ** 64", want: "18446744073709551616"},
+		{name: "negate overflow", exp: "m = -9223372036854775807 - 1; -m", want: "9223372036854775808"},
+		{name: "increment overflow", exp: "m = 9223372036854775807; ++m; m", want: "9223372036854775808"},
+		{name: "overflow then divide", exp: "(9223372036854775807 + 1) / 3", want: "3074457345618258602.67"},
+		{name: "division rounding", exp: "10 / 3.0", want: "3.33"},
+		{name: "division half up", exp: "0.125 / 1.0", want: "0.13"},
+		{name: "division negative", exp: "-2 / 3.0", want: "-0.67"},
+		{name: "remainder", exp: "1.5 % 0.4", want: "0.3"},
+		{name: "negate", exp: "-price", want: "-19.99"},
+		{name: "increment", exp: "++a", want: "1.1"},
+		{name: "power", exp: "1.1 ** 2", want: "1.21"},
+		{name: "negative power", exp: "2.0 ** -1", want: "0.5"},
+		{name: "compare", exp: "0.30 == 0.3 && 1.05 > 1 && a < 0.15 && price in [19.99, 1]", want: true},
+		{name: "json number", exp: "n * 3 == 0.9", want: true},
+		{name: "member", exp: "order.fee * 3", want: "0.9"},
+		{name: "function result", exp: "tenth() * 3 == 0.3", want: true},
+		{name: "lambda", exp: "reduce(items, (s, x) => s + x, 0) == 0.6", want: true},
+		{name: "decimal param", exp: "d + 1", want: "2.05"},
+		{name: "compound assign", exp: "v = 1.0; v /= 8; v", want: "0.13"},
+		{name: "divide by zero", exp: "1.0 / 0", wantErr: "decimal divide by zero"},
+		{name: "int divide by zero", exp: "x / 0", wantErr: "decimal divide by zero"},
+		{name: "invalid operation", exp: "1.5 + 'x'", wantErr: "invalid operation number + string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkExecute(t, tt.exp, tt.want, tt.wantErr, params, functions, WithDecimal(2, RoundHalfUp))
+		})
+	}
+}
+
+func TestWithDecimal_Disabled(t *testing.T) {
+	params := map[string]any{"a": 0.1, "b": 0.2, "d": NewDecimal(1, 0)}
+	e, _ := NewExpression("a + b == 0.3", true, nil)
+	if got, err := e.Bool(params); err != nil || got {
+		t.Errorf("Bool() = %v, %v, want false without WithDecimal", got, err)
+	}
+	// 未开启时 Decimal 参数同样参与精确运算, 除法保留 16 位小数
+	e, _ = NewExpression("d / 3", true, nil)
+	if got, err := e.Decimal(params); err != nil || got.String() != "0.3333333333333333" {
+		t.Errorf("Decimal() = %v, %v, want 0.3333333333333333", got, err)
+	}
+}
+
+func TestWithDecimal_Rounding(t *testing.T) {
+	tests := []struct {
+		rounding Rounding
+		want     string
+	}{
+		{rounding: RoundHalfUp, want: "0.13 -0.13 0.12"},
+		{rounding: RoundHalfEven, want: "0.12 -0.12 0.12"},
+		{rounding: RoundDown, want: "0.12 -0.12 0.12"},
+		{rounding: RoundUp, want: "0.13 -0.13 0.13"},
+		{rounding: RoundFloor, want: "0.12 -0.13 0.12"},
+		{rounding: RoundCeiling, want: "0.13 -0.12 0.13"},
+	}
+	for _, tt := range tests {
+		e, err := NewExpression("[x / 1.0, -x / 1.0, y / 1.0]", true, nil, WithDecimal(2, tt.rounding))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := e.Execute(map[string]any{"x": 0.125, "y": 0.1201})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var parts []string
+		for _, v := range got.([]any) {
+			parts = append(parts, v.(Decimal).String())
+		}
+		if s := strings.Join(parts, " "); s != tt.want {
+			t.Errorf("rounding %d = %s, want %s", tt.rounding, s, tt.want)
+		}
+	}
+}
+
+func TestWithDecimal_Accessors(t *testing.T) {
+	e, err := NewExpression("price * 0.1 + fee", true, nil, WithDecimal(4, RoundHalfEven), WithStdlib())
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := map[string]any{"price": 99.9, "fee": 0.3}
+	if got, err := e.Decimal(params); err != nil || got.Cmp(NewDecimal(1029, 2)) != 0 {
+		t.Errorf("Decimal() = %v, %v, want 10.29", got, err)
+	}
+	if got, err := e.Float64(params); err != nil || got != 10.29 {
+		t.Errorf("Float64() = %v, %v, want 10.29", got, err)
+	}
+	if got, err := e.Int64(params); err != nil || got != 10 {
+		t.Errorf("Int64() = %v, %v, want 10", got, err)
+	}
+	e, _ = NewExpression("round(2.5) + floor(-1.5) + ceil(1.1) + abs(-0.5) == 3.5 && string(1.10) == '1.10' && int(2.9) == 2 && max(0.1, 0.3, 0.2) == 0.3", true, nil, WithDecimal(2, RoundHalfUp), WithStdlib())
+	if got, err := e.Bool(nil); err != nil || !got {
+		t.Errorf("Bool() = %v, %v, want true", got, err)
+	}
+	// PartialEval 代入的 float64 同样转换为 Decimal
+	e, _ = NewExpression("a + b == 0.3", true, nil, WithDecimal(2, RoundHalfUp))
+	residual, _, err := e.PartialEval(map[string]any{"a": 0.1})
+	if err != nil || residual == nil {
+		t.Fatalf("PartialEval() = %v, %v", residual, err)
+	}
+	if got, err := residual.Bool(map[string]any{"b": 0.2}); err != nil || !got {
+		t.Errorf("residual.Bool() = %v, %v, want true", got, err)
+	}
+	schema := Schema{Vars: map[string]Type{"price": TypeNumber, "name": TypeString}}
+	if _, err = NewExpression("price * 0.1 > 1", true, nil, WithDecimal(2, RoundHalfUp), WithSchema(schema)); err != nil {
+		t.Errorf("NewExpression() error = %v", err)
+	}
+	if _, err = NewExpression("name + 0.1 > 1", true, nil, WithDecimal(2, RoundHalfUp), WithSchema(schema)); err == nil {
+		t.Error("NewExpression() error = nil, want type error")
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{s: "10.30", want: "10.30"},
+		{s: "-.5", want: "-0.5"},
+		{s: "+1", want: "1"},
+		{s: "1e-3", want: "0.001"},
+		{s: "1.5E2", want: "150"},
+		{s: "0.000", want: "0.000"},
+		{s: "12345678901234567890.123456789", want: "12345678901234567890.123456789"},
+		{s: "", wantErr: true},
+		{s: ".", wantErr: true},
+		{s: "1.2.3", wantErr: true},
+		{s: "1e", wantErr: true},
+		{s: "1e99999", wantErr: true},
+		{s: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseDecimal(tt.s)
+			if (err != nil) != tt.wantErr || err == nil && got.String() != tt.want {
+				t.Errorf("ParseDecimal() = %v, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+	if d, ok := DecimalFromFloat(0.1); !ok || d.String() != "0.1" {
+		t.Errorf("DecimalFromFloat(0.1) = %v, %v", d, ok)
+	}
+	if zero := (Decimal{}); zero.String() != "0" || zero.Sign() != 0 {
+		t.Errorf("zero Decimal = %v", zero)
+	}
+}
@@ -0,0 +1,225 @@
+package goexpression
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Span 源码区间 [Start, End), 均为字节偏移
+type Span struct {
+	Start, End int
+}
+
+// join 覆盖 s 与 o 的最小区间
+func (s Span) join(o Span) Span {
+	if o.Start < s.Start {
+		s.Start = o.Start
+	}
+	if o.End > s.End {
+		s.End = o.End
+	}
+	return s
+}
+
+// ErrorPos 错误在表达式中的位置, 嵌入在 *SyntaxError、*TypeError、*EvalError 中
+type ErrorPos struct {
+	Src  string   // 表达式源码
+	Span Span     // 出错的源码区间
+	Pos  Position // Span.Start 对应的行列号
+}
+
+func newErrorPos(src string, span Span) ErrorPos {
+	return ErrorPos{Src: src, Span: span, Pos: position(src, span.Start)}
+}
+
+// Snippet 出错所在行及其下方用 ^ 标出的出错区间, 用于展示
+func (e ErrorPos) Snippet() string {
+	return Snippet(e.Src, e.Span)
+}
+
+// SyntaxError 词法或语法错误
+type SyntaxError struct {
+	ErrorPos
+	Msg     string
+	lexical bool // 词法错误, 以 lexer: 开头
+}
+
+func (e *SyntaxError) Error() string {
+	prefix := "syntax"
+	if e.lexical {
+		prefix = "lexer"
+	}
+	return fmt.Sprintf("%s: %v: %s", prefix, e.Pos, e.Msg)
+}
+
+// ErrorList 错误恢复模式下收集的多个语法错误, 按出现位置排序
+type ErrorList []*SyntaxError
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err 没有错误时返回 nil, 否则返回 ErrorList 本身
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+func (l ErrorList) sort() {
+	sort.SliceStable(l, func(i, j int) bool { return l[i].Span.Start < l[j].Span.Start })
+}
+
+// LimitError 超出 WithLimits 设置的资源限制, Err 为具体的限制, 可用 errors.Is 检查, 如 errors.Is(err, ErrStepLimit)
+type LimitError struct {
+	ErrorPos
+	Err   error // ErrLengthLimit、ErrDepthLimit、ErrStepLimit、ErrStringLimit、ErrListLimit 或 ErrCallLimit
+	Limit int   // 设置的限制值
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("limit: %v: %s (limit %d)", e.Pos, strings.TrimPrefix(e.Err.Error(), "limit: "), e.Limit)
+}
+
+func (e *LimitError) Unwrap() error { return e.Err }
+
+// 各项资源限制, 由 *LimitError 包装
+var (
+	ErrLengthLimit = errors.New("limit: expression too long")
+	ErrDepthLimit  = errors.New("limit: expression nested too deeply")
+	ErrStepLimit   = errors.New("limit: too many evaluation steps")
+	ErrStringLimit = errors.New("limit: string result too long")
+	ErrListLimit   = errors.New("limit: list result too long")
+	ErrCallLimit   = errors.New("limit: too many function calls")
+)
+
+// TypeError 类型错误, 声明了 Schema 时在编译期返回, 否则在 NeedCheck 为 true 时于执行期返回
+type TypeError struct {
+	ErrorPos
+	Op       Operator // 出错的操作符, 与操作符无关的错误(如未声明的变量)为 NotOperator
+	Operands []string // 操作数的类型名称
+	Msg      string
+}
+
+func (e *TypeError) Error() string {
+	return fmt.Sprintf("type: %v: %s", e.Pos, e.Msg)
+}
+
+// EvalError 执行错误, 如除零、缺少参数、函数返回错误, 可用 errors.Is/As 检查 Err
+type EvalError struct {
+	ErrorPos
+	Op       Operator // 出错的操作符, 与操作符无关的错误为 NotOperator
+	Operands []string // 操作数的类型名称
+	Err      error
+}
+
+func (e *EvalError) Error() string {
+	return fmt.Sprintf("execute: %v: %s", e.Pos, strings.TrimPrefix(e.Err.Error(), "execute: "))
+}
+
+func (e *EvalError) Unwrap() error { return e.Err }
+
+// newOperandTypeError 操作数类型不匹配的 TypeError, 位置由调用方补充
+func newOperandTypeError(op Operator, binary bool, operands ...string) *TypeError {
+	msg := fmt.Sprintf("invalid operation %v%s", op, operands[0])
+	if binary {
+		msg = fmt.Sprintf("invalid operation %s %v %s", operands[0], op, operands[1])
+	}
+	return &TypeError{Op: op, Operands: operands, Msg: msg}
+}
+
+// typeName 值的类型名称, Schema 中的类型使用 Type 的名称, 其余为 Go 类型
+func typeName(v any) string {
+	if v == nil {
+		return "nil"
+	}
+	if _, ok := v.(*regexp.Regexp); ok { // 预编译的正则表达式字面量
+		return TypeString.String()
+	}
+	if t := typeOf(v); t != TypeAny {
+		return t.String()
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// position 计算 src 中字节偏移 offset 对应的行列号
+func position(src string, offset int) Position {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	pos := Position{Offset: offset, Line: 1, Column: 1}
+	for _, r := range src[:offset] {
+		if r == '\n' {
+			pos.Line++
+			pos.Column = 1
+			continue
+		}
+		pos.Column++
+	}
+	return pos
+}
+
+// Snippet 渲染 src 中 span 起始所在的行, 并在下一行用 ^ 标出 span, 如
+//
+//	a + true
+//	    ^^^^
+//
+// span 跨行时只标出第一行的部分, 空区间(如表达式意外结束)标出一个 ^
+// 中文等宽字符按两列计算, 制表符原样保留, 以便在等宽字体下对齐
+func Snippet(src string, span Span) string {
+	start := span.Start
+	if start < 0 {
+		start = 0
+	} else if start > len(src) {
+		start = len(src)
+	}
+	lineStart := strings.LastIndexByte(src[:start], '\n') + 1
+	lineEnd := len(src)
+	if i := strings.IndexByte(src[start:], '\n'); i >= 0 {
+		lineEnd = start + i
+	}
+	end := span.End
+	if end < start {
+		end = start
+	} else if end > lineEnd {
+		end = lineEnd
+	}
+
+	var caret strings.Builder
+	for _, r := range src[lineStart:start] {
+		if r == '\t' {
+			caret.WriteByte('\t')
+			continue
+		}
+		caret.WriteString(strings.Repeat(" ", runeWidth(r)))
+	}
+	width := 0
+	for _, r := range src[start:end] {
+		width += runeWidth(r)
+	}
+	if width == 0 {
+		width = 1
+	}
+	caret.WriteString(strings.Repeat("^", width))
+	return strings.TrimRight(src[lineStart:lineEnd], "\r") + "\n" + caret.String()
+}
+
+// runeWidth 字符在等宽字体下占用的列数
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Han, unicode.Hangul, unicode.Hiragana, unicode.Katakana) ||
+		r >= 0x3000 && r <= 0x303f || r >= 0xff01 && r <= 0xff60 || r >= 0xffe0 && r <= 0xffe6 {
+		return 2
+	}
+	return 1
+}
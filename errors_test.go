@@ -0,0 +1,180 @@
+package goexpression
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	params := map[string]any{"a": int64(1), "s": "x", "m": map[string]any{}}
+	functions := map[string]Function{
+		"fail": func(...any) (any, error) { return nil, errors.New("boom") },
+	}
+	schema := Schema{Vars: map[string]Type{"a": TypeInt, "s": TypeString}}
+	tests := []struct {
+		name        string
+		exp         string
+		opts        []Option
+		compileErr  bool
+		wantErr     any // *SyntaxError、*TypeError、*EvalError
+		wantMsg     string
+		wantSpan    Span
+		wantOp      Operator
+		wantOperand []string
+		wantSnippet string
+	}{
+		{
+			name: "illegal token", exp: "a + * 2", compileErr: true, wantErr: &SyntaxError{},
+			wantMsg: "syntax: line 1, column 5 (offset 4): parse unaryExpr illegal operator *", wantSpan: Span{4, 5},
+			wantSnippet: "a + * 2\n    ^",
+		},
+		{
+			name: "unmatched paren", exp: "(a + (1 * 2)", compileErr: true, wantErr: &SyntaxError{},
+			wantMsg: "syntax: line 1, column 1 (offset 0): ( lack of )", wantSpan: Span{0, 1},
+		},
+		{
+			name: "unmatched bracket", exp: "a in [1, 2))", compileErr: true, wantErr: &SyntaxError{},
+			wantMsg: "syntax: line 1, column 11 (offset 10): unmatched )", wantSpan: Span{10, 11},
+		},
+		{
+			name: "lexer", exp: "a > 1 &&\n年龄 # 2", compileErr: true, wantErr: &SyntaxError{},
+			wantMsg: "lexer: line 2, column 4 (offset 16): character '#' illegal", wantSpan: Span{16, 17},
+			wantSnippet: "年龄 # 2\n     ^",
+		},
+		{
+			name: "schema type", exp: "s == 'x' && a + true > 1", opts: []Option{WithSchema(schema)}, compileErr: true,
+			wantErr: &TypeError{}, wantMsg: "type: line 1, column 13 (offset 12): invalid operation int + bool",
+			wantSpan: Span{12, 20}, wantOp: Add, wantOperand: []string{"int", "bool"},
+			wantSnippet: "s == 'x' && a + true > 1\n            ^^^^^^^^",
+		},
+		{
+			name: "schema undeclared", exp: "a > b", opts: []Option{WithSchema(schema)}, compileErr: true,
+			wantErr: &TypeError{}, wantMsg: "type: line 1, column 5 (offset 4): variable b is not declared in schema",
+			wantSpan: Span{4, 5},
+		},
+		{
+			name: "runtime type", exp: "a > 0 && -s == 1", wantErr: &TypeError{},
+			wantMsg: "type: line 1, column 10 (offset 9): invalid operation -string", wantSpan: Span{9, 11},
+			wantOp: Minus, wantOperand: []string{"string"},
+		},
+		{
+			name: "divide by zero", exp: "10 + a / (a - 1)", wantErr: &EvalError{},
+			wantMsg: "execute: line 1, column 6 (offset 5): integer divide by zero", wantSpan: Span{5, 15},
+			wantOp: Div, wantOperand: []string{"int", "int"},
+			wantSnippet: "10 + a / (a - 1)\n     ^^^^^^^^^^",
+		},
+		{
+			name: "missing param", exp: "a + b", wantErr: &EvalError{},
+			wantMsg: "execute: line 1, column 5 (offset 4): b param not in the passed parameter list", wantSpan: Span{4, 5},
+		},
+		{
+			name: "function", exp: "a == 1 && fail(a, 2)", wantErr: &EvalError{},
+			wantMsg: "execute: line 1, column 11 (offset 10): boom", wantSpan: Span{10, 20},
+		},
+		{
+			name: "member", exp: "m.x.y", wantErr: &EvalError{},
+			wantMsg: `execute: line 1, column 1 (offset 0): key "x" not found in map`, wantSpan: Span{0, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExpression(tt.exp, true, functions, tt.opts...)
+			if err == nil && tt.compileErr {
+				t.Fatalf("NewExpression() want error")
+			}
+			if err == nil {
+				_, err = e.Execute(params)
+			} else if !tt.compileErr {
+				t.Fatalf("NewExpression() error = %v", err)
+			}
+			if err == nil || err.Error() != tt.wantMsg {
+				t.Fatalf("error = %v, want %s", err, tt.wantMsg)
+			}
+
+			var (
+				pos      ErrorPos
+				op       Operator
+				operands []string
+			)
+			switch tt.wantErr.(type) {
+			case *SyntaxError:
+				var se *SyntaxError
+				if !errors.As(err, &se) {
+					t.Fatalf("error type = %T, want %T", err, tt.wantErr)
+				}
+				pos = se.ErrorPos
+			case *TypeError:
+				var te *TypeError
+				if !errors.As(err, &te) {
+					t.Fatalf("error type = %T, want %T", err, tt.wantErr)
+				}
+				pos, op, operands = te.ErrorPos, te.Op, te.Operands
+			case *EvalError:
+				var ee *EvalError
+				if !errors.As(err, &ee) {
+					t.Fatalf("error type = %T, want %T", err, tt.wantErr)
+				}
+				pos, op, operands = ee.ErrorPos, ee.Op, ee.Operands
+			}
+			if pos.Span != tt.wantSpan || pos.Src != tt.exp {
+				t.Errorf("span = %v, want %v", pos.Span, tt.wantSpan)
+			}
+			if op != tt.wantOp || !reflect.DeepEqual(operands, tt.wantOperand) {
+				t.Errorf("op = %v %v, want %v %v", op, operands, tt.wantOp, tt.wantOperand)
+			}
+			if tt.wantSnippet != "" && pos.Snippet() != tt.wantSnippet {
+				t.Errorf("Snippet() = \n%s\nwant\n%s", pos.Snippet(), tt.wantSnippet)
+			}
+		})
+	}
+}
+
+func TestEvalError_Unwrap(t *testing.T) {
+	e, err := NewExpression("1 / a", true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = e.Execute(map[string]any{"a": 0}); !errors.Is(err, errIntegerDivideByZero) {
+		t.Errorf("Execute() error = %v, want %v", err, errIntegerDivideByZero)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		span Span
+		want string
+	}{
+		{name: "single line", src: "a + true", span: Span{4, 8}, want: "a + true\n    ^^^^"},
+		{name: "empty span at end", src: "a +", span: Span{3, 3}, want: "a +\n   ^"},
+		{name: "second line", src: "a &&\n\tb == 'x'", span: Span{6, 14}, want: "\tb == 'x'\n\t^^^^^^^^"},
+		{name: "cross lines", src: "(a &&\nb)", span: Span{0, 8}, want: "(a &&\n^^^^^"},
+		{name: "wide chars", src: "城市 == 1", span: Span{7, 9}, want: "城市 == 1\n     ^^"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Snippet(tt.src, tt.span); got != tt.want {
+				t.Errorf("Snippet() = \n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLexer_TokenSpan(t *testing.T) {
+	src := "年龄 >= 18 && f1('北京')"
+	l := newLexer(src)
+	if err := l.Parse(map[string]Function{"f1": nil}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"年龄", ">=", "18", "&&", "f1", "(", "'北京'", ")"}
+	if len(l.Tokens) != len(want) {
+		t.Fatalf("Parse() got %d tokens, want %d", len(l.Tokens), len(want))
+	}
+	for i, token := range l.Tokens {
+		if got := src[token.Span.Start:token.Span.End]; got != want[i] {
+			t.Errorf("token %d span = %q, want %q", i, got, want[i])
+		}
+	}
+}
@@ -1,67 +1,32 @@
 package goexpression
 
 import (
+	"context"
 	"fmt"
 )
 
-// Expression expression
+// Expression 编译后的表达式, 可被多个 goroutine 同时执行
+// 每次执行使用独立的栈, 不修改表达式与传入的 params; 注册的函数在编译时复制, 之后修改传入的函数 map 不影响表达式
+// 函数可能被并发调用, 持有状态的函数需自行保证并发安全
+// NeedCheck 是唯一可修改的字段, 需在并发执行之前设置
 type Expression struct {
 	root      *astNode
-	NeedCheck bool
+	prog      *program
+	NeedCheck bool // 执行时是否检查操作数类型
 }
 
 // Execute 执行表达式
 func (e *Expression) Execute(params map[string]any) (any, error) {
-	if e.root == nil {
-		return nil, fmt.Errorf("execute: parse result is nil")
-	}
-	return e.executeASTNode(e.root, params)
+	return e.ExecuteContext(context.Background(), params)
 }
 
-func (e *Expression) executeASTNode(root *astNode, params map[string]any) (any, error) {
-	if root == nil {
-		return nil, nil
-	}
-	var (
-		left, right any
-		err         error
-	)
-
-	if left, err = e.executeASTNode(root.left, params); err != nil {
-		return nil, err
-	}
-
-	// 左边结果可能可以直接决定结果的
-	switch root.op {
-	case AndAnd:
-		if left == false {
-			return false, nil
-		}
-	case OrOr:
-		if left == true {
-			return true, nil
-		}
-	case TernaryT:
-		if left == false {
-			return nil, nil
-		}
-	case TernaryF:
-		if left != nil {
-			return left, nil
-		}
-	default:
-	}
-
-	if right, err = e.executeASTNode(root.right, params); err != nil {
-		return nil, err
-	}
-
-	if e.NeedCheck && root.typeCheck != nil {
-		if !root.typeCheck(left, right) {
-			return nil, fmt.Errorf("execute: type check error")
-		}
+// ExecuteContext 执行表达式, 每条指令执行前检查 ctx 是否已取消, ctx 会传给 ContextFunction
+// ctx 取消或超时时返回 *EvalError, 可用 errors.Is(err, context.Canceled) 等检查原因
+func (e *Expression) ExecuteContext(ctx context.Context, params map[string]any) (any, error) {
+	if e.root == nil || e.prog == nil {
+		return nil, fmt.Errorf("execute: parse result is nil")
 	}
-	return root.opFunc(left, right, params)
+	return e.prog.run(ctx, params, e.NeedCheck)
 }
 
 // Bool 计算bool结果
@@ -90,39 +55,58 @@ func (e *Expression) Str(params map[string]any) (string, error) {
 	return s, nil
 }
 
-// Int64 returns int64 value
+// Int64 returns int64 value, float64 and Decimal results are truncated
 func (e *Expression) Int64(params map[string]any) (int64, error) {
 	ret, err := e.Execute(params)
 	if err != nil {
 		return 0, err
 	}
-	num, ok := ret.(float64)
+	num, ok := toInt64(ret)
 	if !ok {
 		return 0, fmt.Errorf("execute: the result( %+v ) is not of int64 type", ret)
 	}
-	return int64(num), nil
+	return num, nil
 }
 
-// Float64 returns float64 value
+// Float64 returns float64 value, int64 and Decimal results are converted
 func (e *Expression) Float64(params map[string]any) (float64, error) {
 	ret, err := e.Execute(params)
 	if err != nil {
 		return 0.0, err
 	}
-	num, ok := ret.(float64)
+	num, ok := toFloat64(ret)
 	if !ok {
 		return 0.0, fmt.Errorf("execute: the result( %+v ) is not of float64 type", ret)
 	}
 	return num, nil
 }
 
+// Decimal returns Decimal value, int64 and float64 results are converted, 通常与 WithDecimal 一起使用
+func (e *Expression) Decimal(params map[string]any) (Decimal, error) {
+	ret, err := e.Execute(params)
+	if err != nil {
+		return Decimal{}, err
+	}
+	d, ok := toDecimal(ret)
+	if !ok {
+		return Decimal{}, fmt.Errorf("execute: the result( %+v ) is not of decimal type", ret)
+	}
+	return d, nil
+}
+
 // NewExpression creates a new expression
-func NewExpression(exp string, needCheck bool, functions map[string]Function) (*Expression, error) {
+func NewExpression(exp string, needCheck bool, functions map[string]Function, opts ...Option) (*Expression, error) {
 	var (
-		p          = newParse(exp)
+		cfg        = newConfig(opts)
+		p          = newParse(exp, cfg)
 		expression = &Expression{NeedCheck: needCheck}
 		err        error
 	)
-	expression.root, err = p.OnceParse(functions)
+	if expression.root, err = p.OnceParse(functions); err != nil {
+		return expression, err
+	}
+	if expression.root != nil {
+		expression.prog, err = compile(expression.root, exp, cfg)
+	}
 	return expression, err
 }
@@ -3,6 +3,9 @@ package goexpression
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -45,6 +48,7 @@ func TestExpression_Bool(t *testing.T) {
 
 	type fields struct {
 		Root      *astNode
+		Prog      *program
 		NeedCheck bool
 	}
 	type args struct {
@@ -59,7 +63,7 @@ func TestExpression_Bool(t *testing.T) {
 	}{
 		{
 			name:   "TestExpression_Bool-Normal1",
-			fields: fields{Root: exp1.root, NeedCheck: exp1.NeedCheck},
+			fields: fields{Root: exp1.root, Prog: exp1.prog, NeedCheck: exp1.NeedCheck},
 			args: args{map[string]any{
 				"a": 100.0, // 传参数值型一律为 float64
 				"c": 50.0,
@@ -70,7 +74,7 @@ func TestExpression_Bool(t *testing.T) {
 		},
 		{
 			name:   "TestExpression_Bool-Normal2",
-			fields: fields{Root: exp2.root, NeedCheck: exp2.NeedCheck},
+			fields: fields{Root: exp2.root, Prog: exp2.prog, NeedCheck: exp2.NeedCheck},
 			args: args{map[string]any{
 				"ctx": context.WithValue(context.Background(), "ctx", 1),
 			}},
@@ -79,7 +83,7 @@ func TestExpression_Bool(t *testing.T) {
 		},
 		{
 			name:   "TestExpression_Bool-Normal3",
-			fields: fields{Root: exp3.root, NeedCheck: exp3.NeedCheck},
+			fields: fields{Root: exp3.root, Prog: exp3.prog, NeedCheck: exp3.NeedCheck},
 			args: args{map[string]any{
 				"a": true,
 				"b": true,
@@ -93,6 +97,7 @@ func TestExpression_Bool(t *testing.T) {
 			e := &Expression{
 				NeedCheck: tt.fields.NeedCheck,
 				root:      tt.fields.Root,
+				prog:      tt.fields.Prog,
 			}
 			got, err := e.Bool(tt.args.params)
 			if (err != nil) != tt.wantErr {
@@ -105,3 +110,71 @@ func TestExpression_Bool(t *testing.T) {
 		})
 	}
 }
+
+// TestExpression_Concurrent 多个 goroutine 共享同一个表达式, 配合 go test -race 检查数据竞争
+func TestExpression_Concurrent(t *testing.T) {
+	type user struct {
+		Name string
+		Age  int
+	}
+	e, err := NewExpression("u.Age + n > 20 && name(u) in ['a', 'b'] ? [n, u.Name] : -n", true, map[string]Function{
+		"name": func(params ...any) (any, error) { return params[0].(user).Name, nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				n := int64(g*1000 + i)
+				params := map[string]any{"u": user{Name: "a", Age: 18}, "n": n}
+				want := any([]any{n, "a"})
+				if n <= 2 {
+					want = -n
+				}
+				got, err := e.ExecuteContext(context.Background(), params)
+				if err != nil || !reflect.DeepEqual(got, want) {
+					t.Errorf("Execute(n=%d) = %v, %v, want %v", n, got, err, want)
+					return
+				}
+				if residual, _, err := e.PartialEval(map[string]any{"n": n}); err != nil || residual == nil {
+					t.Errorf("PartialEval(n=%d) = %v, %v", n, residual, err)
+					return
+				}
+				if vars := e.Variables(); len(vars) != 4 {
+					t.Errorf("Variables() = %v", vars)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+// checkExecute 以 functions 与 opts 编译 exp 并传入 params 执行
+// wantErr 非空时期望编译或执行返回包含 wantErr 的错误, 否则结果按 reflect.DeepEqual 与 want 比较, Decimal 结果按 String() 比较
+func checkExecute(t *testing.T, exp string, want any, wantErr string, params map[string]any, functions map[string]Function, opts ...Option) {
+	t.Helper()
+	e, err := NewExpression(exp, true, functions, opts...)
+	if err == nil {
+		var got any
+		if got, err = e.Execute(params); err == nil {
+			if wantErr != "" {
+				t.Fatalf("Execute() = %v, want error %s", got, wantErr)
+			}
+			if d, ok := got.(Decimal); ok {
+				got = d.String()
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Execute() = %#v, want %#v", got, want)
+			}
+			return
+		}
+	}
+	if wantErr == "" || !strings.Contains(err.Error(), wantErr) {
+		t.Errorf("error = %v, want %s", err, wantErr)
+	}
+}
@@ -1,4 +1,20 @@
 package goexpression
 
+import "context"
+
 // Function 函数
 type Function func(params ...any) (any, error)
+
+// ContextFunction 可感知 context 的函数, ctx 为 ExecuteContext 传入的 context, Execute 执行时为 context.Background()
+// 通过 WithContextFunctions 注册, 耗时的函数(如访问缓存、远程服务)应在 ctx 取消时尽快返回
+type ContextFunction func(ctx context.Context, params ...any) (any, error)
+
+// withContext 将 Function 转换为 ContextFunction, 忽略 ctx
+func (f Function) withContext() ContextFunction {
+	if f == nil {
+		return nil
+	}
+	return func(_ context.Context, params ...any) (any, error) {
+		return f(params...)
+	}
+}
@@ -0,0 +1,160 @@
+package goexpression
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExecute_Lambda(t *testing.T) {
+	params := map[string]any{
+		"items": []any{
+			map[string]any{"name": "pen", "price": 5, "qty": 10},
+			map[string]any{"name": "book", "price": 120, "qty": 1},
+			map[string]any{"name": "bag", "price": 300, "qty": 2},
+		},
+		"tags":  []string{"b", "a", "c"},
+		"limit": 100,
+		"empty": []any{},
+		"count": 3,
+	}
+	functions := map[string]Function{
+		"nums": func(params ...any) (any, error) { return []any{3, 1, 2}, nil },
+	}
+	tests := []struct {
+		name    string
+		exp     string
+		want    any
+		wantErr string
+	}{
+		{name: "any", exp: "any(items, x => x.price > 100)", want: true},
+		{name: "all", exp: "all(items, x => x.qty > 0)", want: true},
+		{name: "none", exp: "none(items, x => x.price > 1000)", want: true},
+		{name: "all of empty", exp: "all(empty, x => false)", want: true},
+		{name: "any of nil", exp: "any(missing, x => true)", wantErr: "missing param not in the passed parameter list"},
+		{name: "filter", exp: "filter([1, 2, 3, 4], x => x % 2 == 0)", want: []any{int64(2), int64(4)}},
+		{name: "filter none", exp: "filter(items, x => x.price > 1000)", want: []any{}},
+		{name: "map", exp: "map(items, x => x.name)", want: []any{"pen", "book", "bag"}},
+		{name: "count", exp: "count(items, x => x.price >= limit)", want: int64(2)},
+		{name: "count variable", exp: "count + 1", want: int64(4)},
+		{name: "find", exp: "find(items, x => x.price > 100).name", want: "book"},
+		{name: "find none", exp: "find(items, x => x.price > 1000)", want: nil},
+		{name: "reduce", exp: "reduce(items, (sum, x) => sum + x.price * x.qty, 0)", want: int64(770)},
+		{name: "reduce without init", exp: "reduce([1, 2, 3], (a, b) => a * 10 + b)", want: int64(123)},
+		{name: "reduce empty", exp: "reduce(empty, (a, b) => a + b)", wantErr: "reduce of empty list with no initial value"},
+		{name: "sortBy", exp: "map(sortBy(items, x => -x.price), x => x.name)", want: []any{"bag", "book", "pen"}},
+		{name: "sortBy strings", exp: "sortBy(tags, x => x)", want: []any{"a", "b", "c"}},
+		{name: "sortBy function", exp: "sortBy(nums(), x => x)", want: []any{1, 2, 3}},
+		{name: "sortBy incomparable", exp: "sortBy([1, 'a'], x => x)", wantErr: "invalid operation"},
+		{name: "nested capture", exp: "filter(items, x => any(items, y => y.price > x.price * 20))", want: []any{params["items"].([]any)[0]}},
+		{name: "assign outer", exp: "total = 0; map(items, x => total += x.qty); total", want: int64(13)},
+		{name: "param shadows outer", exp: "x = 1; any([5, 6], x => x == 5) && x == 1", want: true},
+		{name: "predicate not bool", exp: "filter(items, x => x.price)", wantErr: "filter lambda must return bool, got int"},
+		{name: "not a list", exp: "all(limit, x => true)", wantErr: "execute: line 1, column 1 (offset 0): all expects a list, got int"},
+		{name: "error inside lambda", exp: "all(items, x => x.price / 0 > 1)", wantErr: "column 17"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkExecute(t, tt.exp, tt.want, tt.wantErr, params, functions)
+		})
+	}
+}
+
+func TestLambda_Syntax(t *testing.T) {
+	tests := []struct {
+		name    string
+		exp     string
+		wantErr string
+	}{
+		{name: "outside builtin", exp: "x => x > 1", wantErr: "syntax: line 1, column 1 (offset 0): lambda can only be an argument of built-in collection functions"},
+		{name: "missing lambda", exp: "all([1], 1)", wantErr: "syntax: line 1, column 10 (offset 9): all argument 2 must be a lambda with 1 parameter(s)"},
+		{name: "wrong params", exp: "reduce([1], x => x)", wantErr: "syntax: line 1, column 13 (offset 12): reduce argument 2 must be a lambda with 2 parameter(s)"},
+		{name: "too many arguments", exp: "map([1], x => x, 1)", wantErr: "syntax: line 1, column 1 (offset 0): map expects 2 arguments, got 3"},
+		{name: "lambda as list", exp: "map(x => x, [1])", wantErr: "syntax: line 1, column 5 (offset 4): map argument 1 cannot be a lambda"},
+		{name: "incomplete lambda", exp: "map([1], x =>)", wantErr: "syntax: line 1, column 14 (offset 13): illegal ) after =>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewExpression(tt.exp, true, nil)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("NewExpression() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLambda_Schema(t *testing.T) {
+	schema := Schema{Vars: map[string]Type{"items": TypeList, "name": TypeString, "x": TypeString}}
+	tests := []struct {
+		name    string
+		exp     string
+		wantErr string
+	}{
+		{name: "predicate", exp: "count(items, x => x.price > 1) > 1 && any(items, x => x.ok)"},
+		{name: "map result", exp: "'a' in map(items, x => x.name)"},
+		{name: "param shadows declared", exp: "all(items, x => x > 1) && x == 'a'"},
+		{name: "not a list", exp: "all(name, x => true)", wantErr: "type: line 1, column 5 (offset 4): all argument 1 expects list, got string"},
+		{name: "predicate not bool", exp: "any(items, x => 'a')", wantErr: "type: line 1, column 17 (offset 16): any lambda must return bool, got string"},
+		{name: "count is int", exp: "count(items, x => true) && true", wantErr: "type: line 1, column 1 (offset 0): invalid operation int && bool"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewExpression(tt.exp, true, nil, WithSchema(schema))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("NewExpression() error = %v", err)
+				}
+				return
+			}
+			var te *TypeError
+			if !errors.As(err, &te) || err.Error() != tt.wantErr {
+				t.Errorf("NewExpression() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+	if _, ok := schema.Vars["x"]; !ok {
+		t.Error("lambda parameter removed the declared variable")
+	}
+}
+
+func TestLambda_Refs(t *testing.T) {
+	e, err := NewExpression("filter(items, x => x.price > limit && ok(x))", true, map[string]Function{"ok": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Variables(); !reflect.DeepEqual(got, []string{"items", "limit"}) {
+		t.Errorf("Variables() = %v, want [items limit]", got)
+	}
+	if got := e.Functions(); !reflect.DeepEqual(got, []string{"filter", "ok"}) {
+		t.Errorf("Functions() = %v, want [filter ok]", got)
+	}
+	e, _ = NewExpression("count([1, 2, 3], x => x > 1)", true, nil)
+	if !e.IsConstant() {
+		t.Error("IsConstant() = false, want true")
+	}
+
+	// PartialEval 不代入 lambda 的参数
+	e, _ = NewExpression("any(items, x => x > limit)", true, nil)
+	residual, _, err := e.PartialEval(map[string]any{"x": 100, "limit": 2})
+	if err != nil || residual == nil {
+		t.Fatalf("PartialEval() = %v, %v, want residual", residual, err)
+	}
+	if got, err := residual.Execute(map[string]any{"items": []any{1, 3}}); err != nil || got != true {
+		t.Errorf("residual.Execute() = %v, %v, want true", got, err)
+	}
+}
+
+func TestLambda_Limits(t *testing.T) {
+	params := map[string]any{"items": make([]any, 100)}
+	e, err := NewExpression("all(items, x => true)", true, nil, WithLimits(Limits{MaxSteps: 50}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Execute(params); !errors.Is(err, ErrStepLimit) {
+		t.Errorf("Execute() error = %v, want %v", err, ErrStepLimit)
+	}
+	e, _ = NewExpression("map(items, x => 1)", true, nil, WithLimits(Limits{MaxListLen: 10}))
+	if _, err := e.Execute(params); !errors.Is(err, ErrListLimit) {
+		t.Errorf("Execute() error = %v, want %v", err, ErrListLimit)
+	}
+}
@@ -2,15 +2,23 @@ package goexpression
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // lexer 表达式词法分析器
 type lexer struct {
 	*scanner
 	Tokens []*Token
+	start  int // 当前 Token 起始的字节偏移
+
+	stdlib     bool      // 是否启用标准库函数
+	decimal    bool      // 小数解析为 Decimal
+	recovering bool      // 错误恢复模式, 记录错误后继续解析
+	errs       ErrorList // 错误恢复模式下记录的错误
 }
 
 // newLexer creates a new lexer
@@ -27,38 +35,63 @@ func newLexer(src string) *lexer {
 // eg: 1 ++ 2 不会被解析为 '1' '+' '+' '2'
 func (l *lexer) Parse(functions map[string]Function) error {
 	if len(l.Raw) == 0 {
-		return fmt.Errorf("lexer: expression is empty")
+		return l.report(l.errorf(0, "expression is empty"))
 	}
 	var err error
 	for char, hasNext := l.NextChar(); hasNext; char, hasNext = l.NextChar() {
+		l.start = l.Last
 		if unicode.IsSpace(char) {
 			continue
 		}
 		if unicode.IsLetter(char) {
 			name := l.letters(char)
+			if l.afterDot() { // a.b 中的 b 为成员名, 不是关键字或函数
+				l.addToken(name, Var, false)
+				continue
+			}
+			_, registered := functions[name]
+			if registered && (name == "null" || name == "nil") { // 后加入的关键字, 已注册的同名函数仍为函数
+				l.addToken(name, Func, false)
+				continue
+			}
 			if ok := l.isKeyLetter(name); ok { // 关键字优先级最大
 				continue
 			}
-			if f, ok := functions[name]; ok { // 注册的函数
-				l.addToken(f, Func, false)
+			if registered { // 注册的函数
+				l.addToken(name, Func, false)
+				continue
+			}
+			if l.library(name) && l.beforeParen() { // 内置集合函数与标准库函数, 未跟 ( 时仍可作为变量名
+				l.addToken(name, Func, false)
 				continue
 			}
 			l.addToken(name, Var, false)
 			continue
 		}
 		switch char {
-		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '.':
+		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 			err = l.number(char)
+		case '.':
+			err = l.dot()
 		case '|':
 			l.double('|')
 		case '&':
 			l.and()
 		case '=':
-			cur, ok := l.NextChar()
-			if !ok || cur != '=' {
-				return fmt.Errorf("lexer: index: %d need to be '=' ", l.Index-1)
+			if cur, ok := l.Peek(); ok && cur == '=' {
+				_, _ = l.NextChar()
+				l.addToken("==", Op, true)
+			} else if ok && cur == '>' {
+				_, _ = l.NextChar()
+				l.addToken("=>", Arrow, false)
+			} else if ok && cur == '~' {
+				_, _ = l.NextChar()
+				l.addToken("=~", Op, true)
+			} else {
+				l.addToken("=", Assign, false)
 			}
-			l.addToken("==", Op, true)
+		case ';':
+			l.addToken(";", Semicolon, false)
 		case '!':
 			l.not()
 		case '<':
@@ -75,15 +108,15 @@ func (l *lexer) Parse(functions map[string]Function) error {
 			// 负号逻辑在语法分析中区分
 			l.double('-')
 		case '^':
-			l.addToken("^", Op, true)
+			l.operator("^")
 		case '*':
 			l.double('*')
 		case '/':
-			l.addToken("/", Op, true)
+			l.operator("/")
 		case '%':
-			l.addToken("%", Op, true)
+			l.operator("%")
 		case '?':
-			l.addToken("?", Op, true)
+			l.question()
 		case ':':
 			l.addToken(":", Op, true)
 		case '~':
@@ -96,39 +129,69 @@ func (l *lexer) Parse(functions map[string]Function) error {
 			l.addToken(",", Comma, false)
 		case '\'':
 			err = l.stdStr('\'')
+		case utf8.RuneError:
+			err = l.errorf(l.Last, "invalid UTF-8 encoding")
 		default:
-			return fmt.Errorf("lexer: index: %d character illegal", l.Index-1)
+			err = l.errorf(l.Last, "character %q illegal", char)
 		}
 		if err != nil {
-			return err
+			if err = l.report(err); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
+// report 错误恢复模式下记录错误并返回 nil, 同一位置只记录第一个错误; 否则原样返回错误
+func (l *lexer) report(err error) error {
+	se, ok := err.(*SyntaxError)
+	if !l.recovering || !ok {
+		return err
+	}
+	for _, e := range l.errs {
+		if e.Span.Start == se.Span.Start {
+			return nil
+		}
+	}
+	l.errs = append(l.errs, se)
+	return nil
+}
+
+// errorf 生成携带源码位置的错误
+func (l *lexer) errorf(offset int, format string, args ...any) *SyntaxError {
+	return &SyntaxError{
+		ErrorPos: newErrorPos(l.Raw, Span{Start: offset, End: l.Index}),
+		Msg:      fmt.Sprintf(format, args...),
+		lexical:  true,
+	}
+}
+
 func (l *lexer) addToken(raw any, typ TokenKind, isOp bool) {
 	if isOp {
 		op, ok := raw.(string)
 		if !ok {
 			panic(fmt.Sprintf("invalid operation: %v", raw))
 		}
-		l.Tokens = append(l.Tokens, &Token{Raw: raw, Type: Op, Operator: opMap[op]})
+		l.Tokens = append(l.Tokens, &Token{Raw: raw, Type: Op, Operator: opMap[op], Span: Span{l.start, l.Index}})
 		return
 	}
-	l.Tokens = append(l.Tokens, &Token{Raw: raw, Type: typ})
+	l.Tokens = append(l.Tokens, &Token{Raw: raw, Type: typ, Span: Span{l.start, l.Index}})
 }
 
 func (l *lexer) stdStr(end rune) error {
-	curIndex := l.Index
+	start := l.Last
 	builder := strings.Builder{}
 	for char, hasNext := l.NextChar(); hasNext; char, hasNext = l.NextChar() {
 		if char == '\\' {
+			escape := l.Last
 			char, hasNext = l.NextChar()
 			if !hasNext {
-				return fmt.Errorf("lexer: index %d \\\\ after is end, need '", l.Index-2)
+				l.addToken(builder.String(), StrLit, false)
+				return l.errorf(escape, "\\ after is end, need %q", end)
 			}
-			if char != end {
-				return fmt.Errorf("lexer: index %d \\\\ after char need '", l.Index-2)
+			if char != end { // 只有 \' 是转义, 其余反斜杠原样保留, 便于书写 '\d+' 这样的正则表达式
+				builder.WriteRune('\\')
 			}
 			builder.WriteRune(char)
 			continue
@@ -139,29 +202,290 @@ func (l *lexer) stdStr(end rune) error {
 		}
 		builder.WriteRune(char)
 	}
-	return fmt.Errorf("lexer: index %d \" missing right ' or \"", curIndex-1)
+	l.addToken(builder.String(), StrLit, false) // 错误恢复时按字符串继续解析
+	return l.errorf(start, "string missing right %q", end)
 }
 
-// TODO(bioit): 当有指数/进制等需求时改进
+// number 解析 Go 风格的数值字面量, start 为已读取的第一个字符(数字或 .)
+//   - 十进制整数与小数, 小数可以省略整数部分或小数部分, 可以带指数, 如 1.5、.5、1.、1e9、2.5E-3
+//   - 0x、0b、0o 前缀的十六进制、二进制、八进制整数, 如 0xFF、0b1010、0o755
+//     超过 int64 范围但不超过 64 位的按补码解析为 int64, 便于书写位掩码, 如 0xFFFFFFFFFFFFFFFF 为 -1
+//   - 十六进制浮点数, 必须带 p 指数, 如 0x1p-2、0x1.8p1
+//   - 数字之间可以用一个 _ 分隔, 如 1_000_000、0x_FF_FF
+//   - 十进制数值后紧跟时长单位时为时长, 如 7d、1h30m
+//   - 负号后的 9223372036854775808 为 int64 的最小值, 如 -9223372036854775808
+//
+// 不含小数点与指数的为整数, 解析为 int64, 否则解析为 float64(精确小数模式下为 Decimal)
+// 格式错误时错误指向出错的字符, 如 1.2.3 中的第二个 .、0b102 中的 2
 func (l *lexer) number(start rune) error {
+	offset := l.Last
+	base := 10
+	if start == '0' && l.Index < len(l.Raw) {
+		switch l.Raw[l.Index] {
+		case 'x', 'X':
+			base = 16
+		case 'b', 'B':
+			base = 2
+		case 'o', 'O':
+			base = 8
+		}
+	}
+	if base != 10 {
+		l.Index++ // 前缀
+		digits, err := l.digits(base, true)
+		if err != nil {
+			return err
+		}
+		if base == 16 && l.Index < len(l.Raw) && strings.IndexByte(".pP", l.Raw[l.Index]) >= 0 {
+			return l.hexFloat(offset, digits)
+		}
+		if digits == "" {
+			return l.numberError(l.Index, l.Index, "%s literal has no digits", baseNames[base])
+		}
+		if err = l.numberEnd(offset); err != nil {
+			return err
+		}
+		if l.Index < len(l.Raw) && (l.Raw[l.Index] == 'p' || l.Raw[l.Index] == 'P') {
+			return l.numberError(l.Index, l.Index+1, "'%c' exponent requires hexadecimal mantissa", l.Raw[l.Index])
+		}
+		if r, _ := utf8.DecodeRuneInString(l.Raw[l.Index:]); IsVar(r) {
+			return l.numberError(l.Index, l.Index+1, "invalid character %q in %s literal", r, baseNames[base])
+		}
+		return l.intLit(offset, digits, base)
+	}
+
+	mantissa, isFloat := "", start == '.'
+	if isFloat {
+		frac, err := l.digits(10, false)
+		if err != nil {
+			return err
+		}
+		mantissa = "." + frac
+	} else {
+		digits, err := l.digits(10, true)
+		if err != nil {
+			return err
+		}
+		mantissa = string(start) + digits
+		if l.Index < len(l.Raw) && l.Raw[l.Index] == '.' {
+			l.Index++
+			frac, err := l.digits(10, false)
+			if err != nil {
+				return err
+			}
+			mantissa, isFloat = mantissa+"."+frac, true
+		}
+	}
+	if unit := l.durationUnit(); unit != "" {
+		if d, err := parseDuration(mantissa + unit); err == nil {
+			l.Index += len(unit)
+			l.addToken(d, DurationLit, false)
+			return nil
+		}
+	}
+	if l.Index < len(l.Raw) && (l.Raw[l.Index] == 'p' || l.Raw[l.Index] == 'P') {
+		return l.numberError(l.Index, l.Index+1, "'%c' exponent requires hexadecimal mantissa", l.Raw[l.Index])
+	}
+	if l.Index < len(l.Raw) && (l.Raw[l.Index] == 'e' || l.Raw[l.Index] == 'E') {
+		exp, err := l.exponent()
+		if err != nil {
+			return err
+		}
+		mantissa, isFloat = mantissa+"e"+exp, true
+	}
+	if err := l.numberEnd(offset); err != nil {
+		return err
+	}
+	if !isFloat {
+		if len(mantissa) > 1 && mantissa[0] == '0' {
+			return l.numberError(offset, l.Index, "invalid leading zero in %s, use 0o prefix for octal", l.Raw[offset:l.Index])
+		}
+		return l.intLit(offset, mantissa, 10)
+	}
+	if l.decimal {
+		d, err := ParseDecimal(mantissa)
+		if err != nil {
+			return l.numberError(offset, l.Index, "number %s out of range", l.Raw[offset:l.Index])
+		}
+		l.addToken(d, FloatLit, false)
+		return nil
+	}
+	num, err := strconv.ParseFloat(mantissa, 64)
+	if err != nil {
+		return l.numberError(offset, l.Index, "number %s out of float64 range", l.Raw[offset:l.Index])
+	}
+	l.addToken(num, FloatLit, false)
+	return nil
+}
+
+// hexFloat 解析十六进制浮点数的其余部分, digits 为已读取的整数部分, 当前位置为 . 或 p
+func (l *lexer) hexFloat(offset int, digits string) error {
+	mantissa := digits
+	if l.Raw[l.Index] == '.' {
+		l.Index++
+		frac, err := l.digits(16, false)
+		if err != nil {
+			return err
+		}
+		mantissa += "." + frac
+	}
+	if mantissa == "" || mantissa == "." {
+		return l.numberError(l.Index, l.Index, "hexadecimal literal has no digits")
+	}
+	if err := l.numberEnd(offset); err != nil {
+		return err
+	}
+	if l.Index >= len(l.Raw) || (l.Raw[l.Index] != 'p' && l.Raw[l.Index] != 'P') {
+		return l.numberError(l.Index, l.Index, "hexadecimal mantissa requires a 'p' exponent")
+	}
+	exp, err := l.exponent()
+	if err != nil {
+		return err
+	}
+	if err = l.numberEnd(offset); err != nil {
+		return err
+	}
+	num, err := strconv.ParseFloat("0x"+mantissa+"p"+exp, 64)
+	if err != nil {
+		return l.numberError(offset, l.Index, "number %s out of float64 range", l.Raw[offset:l.Index])
+	}
+	if l.decimal {
+		d, _ := DecimalFromFloat(num)
+		l.addToken(d, FloatLit, false)
+		return nil
+	}
+	l.addToken(num, FloatLit, false)
+	return nil
+}
+
+// exponent 读取 e 或 p 之后的指数, 返回带符号的十进制数字, 当前位置为 e 或 p
+func (l *lexer) exponent() (string, error) {
+	l.Index++ // e 或 p
+	sign := ""
+	if l.Index < len(l.Raw) && (l.Raw[l.Index] == '+' || l.Raw[l.Index] == '-') {
+		sign = l.Raw[l.Index : l.Index+1]
+		l.Index++
+	}
+	exp, err := l.digits(10, false)
+	if err != nil {
+		return "", err
+	}
+	if exp == "" {
+		return "", l.numberError(l.Index, l.Index, "exponent has no digits")
+	}
+	return sign + exp, nil
+}
+
+var baseNames = map[int]string{2: "binary", 8: "octal", 10: "decimal", 16: "hexadecimal"}
+
+// digits 从当前位置读取 base 进制的数字, 返回去掉分隔符 _ 的数字
+// _ 只能出现在两个数字之间, afterDigit 表示前面已经有数字或进制前缀, 如 1_000、0x_FF
+// 二进制、八进制中出现 0-9 的其他数字时报告非法数字
+func (l *lexer) digits(base int, afterDigit bool) (string, error) {
 	builder := strings.Builder{}
-	builder.WriteRune(start)
-	for char, ok := l.NextChar(); ok; char, ok = l.NextChar() {
-		if !IsNumber(char) {
-			_ = l.Rewind(1)
+	sep := -1 // 最近一个 _ 的偏移
+	for ; l.Index < len(l.Raw); l.Index++ {
+		c := l.Raw[l.Index]
+		if c == '_' {
+			if !afterDigit {
+				return "", l.numberError(l.Index, l.Index+1, "'_' must separate successive digits")
+			}
+			sep, afterDigit = l.Index, false
+			continue
+		}
+		if digitValue(c) >= base {
+			if '0' <= c && c <= '9' {
+				return "", l.numberError(l.Index, l.Index+1, "invalid digit %q in %s literal", c, baseNames[base])
+			}
 			break
 		}
-		builder.WriteRune(char)
+		builder.WriteByte(c)
+		afterDigit = true
 	}
-	numStr := builder.String()
-	num, err := strconv.ParseFloat(numStr, 10)
+	if sep >= 0 && sep == l.Index-1 {
+		return "", l.numberError(sep, sep+1, "'_' must separate successive digits")
+	}
+	return builder.String(), nil
+}
+
+// digitValue 字符作为数字的值, 不是数字时返回 16
+func digitValue(c byte) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'a' <= c && c <= 'f':
+		return int(c-'a') + 10
+	case 'A' <= c && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return 16
+}
+
+// numberEnd 数值之后紧跟 . 时报告错误, 如 1.2.3、0x1.5
+func (l *lexer) numberEnd(offset int) error {
+	if l.Index < len(l.Raw) && l.Raw[l.Index] == '.' {
+		return l.numberError(l.Index, l.Index+1, "unexpected '.' after number %s", l.Raw[offset:l.Index])
+	}
+	return nil
+}
+
+// intLit 整数字面量, 带前缀的整数按 64 位补码解析; 十进制的 9223372036854775808 只能跟在负号之后
+func (l *lexer) intLit(offset int, digits string, base int) error {
+	if base != 10 {
+		num, err := strconv.ParseUint(digits, base, 64)
+		if err != nil {
+			return l.numberError(offset, l.Index, "integer %s out of 64-bit range", l.Raw[offset:l.Index])
+		}
+		l.addToken(int64(num), IntLit, false)
+		return nil
+	}
+	num, err := strconv.ParseInt(digits, base, 64)
 	if err != nil {
-		return fmt.Errorf("lexer: %s is not a num %+v", numStr, err)
+		if digits != "9223372036854775808" || !l.afterNegation() {
+			return l.numberError(offset, l.Index, "integer %s out of int64 range", l.Raw[offset:l.Index])
+		}
+		num = math.MinInt64 // 取负后仍为 math.MinInt64
 	}
-	l.addToken(num, FloatLit, false)
+	l.addToken(num, IntLit, false)
 	return nil
 }
 
+// afterNegation 上一个 Token 是否为一元的负号
+func (l *lexer) afterNegation() bool {
+	n := len(l.Tokens)
+	if n == 0 || l.Tokens[n-1].Type != Op || l.Tokens[n-1].Operator != Sub {
+		return false
+	}
+	return n == 1 || !l.Tokens[n-2].CanEnd()
+}
+
+// numberError 数值字面量中 [start, end) 处的错误, 之后跳过数值的剩余部分并按数值 0 继续解析
+func (l *lexer) numberError(start, end int, format string, args ...any) error {
+	l.Index = end
+	err := l.errorf(start, format, args...)
+	rest := l.Raw[l.Index:]
+	if n := strings.IndexFunc(rest, func(r rune) bool { return !IsVar(r) && r != '.' }); n >= 0 {
+		l.Index += n
+	} else {
+		l.Index = len(l.Raw)
+	}
+	l.addToken(int64(0), IntLit, false) // 错误恢复时按数值继续解析
+	return err
+}
+
+// durationUnit 数值后紧跟的时长单位部分, 如 1h30m 中的 h30m, 不以字母开头时返回空串
+// 不是合法的时长时仍按数值后跟变量名处理
+func (l *lexer) durationUnit() string {
+	rest := l.Raw[l.Index:]
+	if r, _ := utf8.DecodeRuneInString(rest); !unicode.IsLetter(r) {
+		return ""
+	}
+	if end := strings.IndexFunc(rest, func(r rune) bool { return !IsVar(r) && r != '.' }); end >= 0 {
+		return rest[:end]
+	}
+	return rest
+}
+
 func (l *lexer) letters(start rune) string {
 	builder := strings.Builder{}
 	builder.WriteRune(start)
@@ -185,6 +509,10 @@ func (l *lexer) isKeyLetter(name string) bool {
 		l.addToken(false, BoolLit, false)
 		return true
 	}
+	if low == "null" || low == "nil" {
+		l.addToken(name, NullLit, false) // 字面量的值为 nil, Raw 保留原文用于错误信息
+		return true
+	}
 	if low == "in" {
 		l.addToken(low, Op, true)
 		return true
@@ -192,20 +520,72 @@ func (l *lexer) isKeyLetter(name string) bool {
 	return false
 }
 
+// dot 区分 .5 这样的数值与 a.b 这样的成员访问
+func (l *lexer) dot() error {
+	next, ok := l.Peek()
+	if ok && unicode.IsDigit(next) && (len(l.Tokens) == 0 || !l.Tokens[len(l.Tokens)-1].CanEnd()) {
+		return l.number('.')
+	}
+	l.addToken(".", Dot, false)
+	return nil
+}
+
+// library 是否为内置集合函数或启用的标准库函数
+func (l *lexer) library(name string) bool {
+	if _, ok := builtins[name]; ok {
+		return true
+	}
+	_, ok := stdlib[name]
+	return ok && l.stdlib
+}
+
+// beforeParen 跳过空白后下一个字符是否为 (
+func (l *lexer) beforeParen() bool {
+	rest := strings.TrimLeftFunc(l.Raw[l.Index:], unicode.IsSpace)
+	return strings.HasPrefix(rest, "(")
+}
+
+func (l *lexer) afterDot() bool {
+	if len(l.Tokens) == 0 {
+		return false
+	}
+	last := l.Tokens[len(l.Tokens)-1].Type
+	return last == Dot || last == OptionalDot
+}
+
+// question 区分 ?、?? 与 ?., a ? .5 : 1 中 ? 后的 .5 为数值
+func (l *lexer) question() {
+	cur, ok := l.Peek()
+	switch {
+	case ok && cur == '?':
+		_, _ = l.NextChar()
+		l.addToken("??", Op, true)
+	case ok && cur == '.' && (l.Index+1 >= len(l.Raw) || !unicode.IsDigit(rune(l.Raw[l.Index+1]))):
+		_, _ = l.NextChar()
+		l.addToken("?.", OptionalDot, false)
+	default:
+		l.addToken("?", Op, true)
+	}
+}
+
 func (l *lexer) and() {
 	cur, ok := l.Peek()
 	if ok && cur == '^' {
 		_, _ = l.NextChar()
-		l.addToken("&^", Op, true)
+		l.operator("&^")
 		return
 	}
 	l.double('&')
 }
 
+// not 区分 !、!= 与 !~, 因此 ! 后紧跟 ~ 时需写作 ! ~a
 func (l *lexer) not() {
 	if cur, ok := l.Peek(); ok && cur == '=' {
 		_, _ = l.NextChar()
 		l.addToken("!=", Op, true)
+	} else if ok && cur == '~' {
+		_, _ = l.NextChar()
+		l.addToken("!~", Op, true)
 	} else {
 		l.addToken("!", Op, true)
 	}
@@ -232,10 +612,26 @@ func (l *lexer) greater() {
 }
 
 func (l *lexer) double(c rune) {
+	raw := string(c)
 	if cur, ok := l.Peek(); ok && cur == c {
 		_, _ = l.NextChar()
-		l.addToken(string(c)+string(c), Op, true)
-	} else {
-		l.addToken(string(c), Op, true)
+		raw += raw
+	}
+	l.operator(raw)
+}
+
+// compoundAssign 后跟 '=' 时构成复合赋值的运算符, 如 +=、<<=
+var compoundAssign = map[string]bool{
+	"+": true, "-": true, "*": true, "/": true, "%": true,
+	"&": true, "|": true, "^": true, "&^": true, "<<": true, ">>": true,
+}
+
+// operator 添加操作符 Token, 可以复合赋值的运算符后跟 '=' 时添加赋值 Token
+func (l *lexer) operator(raw string) {
+	if cur, ok := l.Peek(); ok && cur == '=' && compoundAssign[raw] {
+		_, _ = l.NextChar()
+		l.addToken(raw+"=", Assign, false)
+		return
 	}
+	l.addToken(raw, Op, true)
 }
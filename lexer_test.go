@@ -65,7 +65,7 @@ func TestLexer_Parse(t *testing.T) {
 			name: "TestLexer_Parse-error",
 			fields: fields{
 				srcScanner: &scanner{
-					Raw: "+ +++1 =*= 2-- || 1 | 2 && 3 & 4 || b(1, 2) && 2 in [3, 3, 3]",
+					Raw: "+ +++1 #*= 2-- || 1 | 2 && 3 & 4 || b(1, 2) && 2 in [3, 3, 3]",
 				},
 			},
 			args: args{
@@ -90,3 +90,58 @@ func TestLexer_Parse(t *testing.T) {
 		})
 	}
 }
+
+func TestLexer_UTF8(t *testing.T) {
+	tests := []struct {
+		name       string
+		exp        string
+		wantTokens []any
+		wantErr    string
+	}{
+		{name: "chinese string", exp: "'你好' + 'ü'", wantTokens: []any{"你好", "+", "ü"}},
+		{name: "chinese var", exp: "年龄 >= 18 && 城市 == '北京'", wantTokens: []any{"年龄", ">=", int64(18), "&&", "城市", "==", "北京"}},
+		{name: "escape", exp: `'引\'号'`, wantTokens: []any{"引'号"}},
+		{name: "illegal char position", exp: "'中' + #", wantErr: "lexer: line 1, column 7 (offset 8): character '#' illegal"},
+		{name: "multi line position", exp: "年龄 > 1 &&\n  名字 # 'a'", wantErr: "lexer: line 2, column 6 (offset 23): character '#' illegal"},
+		{name: "missing quote position", exp: "a == \n'中文", wantErr: "lexer: line 2, column 1 (offset 6): string missing right '\\''"},
+		{name: "invalid utf8", exp: "a == \xff", wantErr: "lexer: line 1, column 6 (offset 5): invalid UTF-8 encoding"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newLexer(tt.exp)
+			err := l.Parse(nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Parse() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			var got []any
+			for _, token := range l.Tokens {
+				got = append(got, token.Raw)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantTokens) {
+				t.Errorf("Parse() tokens = %v, want %v", got, tt.wantTokens)
+			}
+		})
+	}
+}
+
+func TestScanner_Rewind(t *testing.T) {
+	s := &scanner{Raw: "a你b"}
+	for i := 0; i < 3; i++ {
+		s.NextChar()
+	}
+	if err := s.Rewind(2); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := s.NextChar(); c != '你' {
+		t.Errorf("NextChar() after Rewind(2) = %q, want '你'", c)
+	}
+	if err := s.Rewind(3); err == nil {
+		t.Errorf("Rewind(3) want out of range error")
+	}
+}
@@ -0,0 +1,69 @@
+package goexpression
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWithLimits(t *testing.T) {
+	functions := map[string]Function{
+		"s":    func(params ...any) (any, error) { return strings.Repeat("x", 10), nil },
+		"list": func(params ...any) (any, error) { return make([]any, 10), nil },
+		"ok":   func(params ...any) (any, error) { return true, nil },
+	}
+	tests := []struct {
+		name       string
+		exp        string
+		limits     Limits
+		wantErr    error // nil 表示在限制内正常执行
+		wantOffset int
+		atCompile  bool
+	}{
+		{name: "length", exp: "a + 1 > 2", limits: Limits{MaxLength: 5}, wantErr: ErrLengthLimit, wantOffset: 5, atCompile: true},
+		{name: "length ok", exp: "a + 1 > 2", limits: Limits{MaxLength: 9}},
+		{name: "nested parens", exp: strings.Repeat("(", 20) + "a" + strings.Repeat(")", 20) + " > 1", limits: Limits{MaxDepth: 10}, wantErr: ErrDepthLimit, wantOffset: 10, atCompile: true},
+		{name: "nested unary", exp: strings.Repeat("!", 20) + "ok()", limits: Limits{MaxDepth: 10}, wantErr: ErrDepthLimit, wantOffset: 10, atCompile: true},
+		{name: "long chain", exp: "a" + strings.Repeat(" + a", 20) + " > 1", limits: Limits{MaxDepth: 10}, wantErr: ErrDepthLimit, wantOffset: 0, atCompile: true},
+		{name: "depth ok", exp: "(a + 1) * 2 > 1", limits: Limits{MaxDepth: 10}},
+		{name: "steps", exp: "a > 1 && a < 10", limits: Limits{MaxSteps: 3}, wantErr: ErrStepLimit, wantOffset: 9},
+		{name: "steps ok", exp: "a + 1 > 2 && a < 10", limits: Limits{MaxSteps: 100}},
+		{name: "string concat", exp: "s() + s() == ''", limits: Limits{MaxStringLen: 15}, wantErr: ErrStringLimit, wantOffset: 0},
+		{name: "string from function", exp: "s() == ''", limits: Limits{MaxStringLen: 5}, wantErr: ErrStringLimit, wantOffset: 0},
+		{name: "list from function", exp: "1 in list()", limits: Limits{MaxListLen: 5}, wantErr: ErrListLimit, wantOffset: 5},
+		{name: "list literal", exp: "a in [1, 2, 3]", limits: Limits{MaxListLen: 2}, wantErr: ErrListLimit, wantOffset: 5},
+		{name: "calls", exp: "ok() && ok() && ok()", limits: Limits{MaxCalls: 2}, wantErr: ErrCallLimit, wantOffset: 16},
+		{name: "calls ok", exp: "ok() && ok() && ok()", limits: Limits{MaxCalls: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExpression(tt.exp, true, functions, WithLimits(tt.limits), WithoutOptimization())
+			if err == nil {
+				_, err = e.Execute(map[string]any{"a": 2})
+			} else if !tt.atCompile {
+				t.Fatalf("NewExpression() error = %v", err)
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("error = %v, want nil", err)
+				}
+				return
+			}
+			var le *LimitError
+			if !errors.As(err, &le) || !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want *LimitError wrapping %v", err, tt.wantErr)
+			}
+			if le.Pos.Offset != tt.wantOffset {
+				t.Errorf("error offset = %d, want %d", le.Pos.Offset, tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestLimitError(t *testing.T) {
+	_, err := NewExpression("a + 1 > 2", true, nil, WithLimits(Limits{MaxLength: 5}))
+	want := "limit: line 1, column 6 (offset 5): expression too long (limit 5)"
+	if err == nil || err.Error() != want {
+		t.Errorf("NewExpression() error = %v, want %s", err, want)
+	}
+}
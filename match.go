@@ -0,0 +1,52 @@
+package goexpression
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// patternCacheSize 执行时才确定的正则表达式的缓存数量上限
+const patternCacheSize = 256
+
+// patterns 执行时才确定的正则表达式(如 path =~ rule.pattern)的编译缓存, 所有表达式共享
+// 字面量的正则表达式在 NewExpression 时预编译, 不经过该缓存; 超出上限时随机淘汰一个
+var patterns = struct {
+	sync.RWMutex
+	m map[string]*regexp.Regexp
+}{m: make(map[string]*regexp.Regexp)}
+
+// pattern =~ 与 !~ 右操作数对应的正则表达式
+func pattern(op Operator, left, right any) (*regexp.Regexp, error) {
+	switch r := right.(type) {
+	case *regexp.Regexp:
+		return r, nil
+	case string:
+		return compilePattern(r)
+	}
+	return nil, invalidOperation(op, left, right)
+}
+
+// compilePattern 编译并缓存正则表达式, 编译失败的不缓存
+func compilePattern(expr string) (*regexp.Regexp, error) {
+	patterns.RLock()
+	re, ok := patterns.m[expr]
+	patterns.RUnlock()
+	if ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("execute: invalid regular expression %q: %w", expr, err)
+	}
+	patterns.Lock()
+	if len(patterns.m) >= patternCacheSize {
+		for k := range patterns.m {
+			delete(patterns.m, k)
+			break
+		}
+	}
+	patterns.m[expr] = re
+	patterns.Unlock()
+	return re, nil
+}
@@ -0,0 +1,119 @@
+package goexpression
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestExecute_Match(t *testing.T) {
+	params := map[string]any{
+		"path":    "/api/v1/users/42",
+		"level":   "ERROR",
+		"rule":    `^/api/v\d+/`,
+		"bad":     "[",
+		"code":    500,
+		"pattern": "(",
+	}
+	tests := []struct {
+		name    string
+		exp     string
+		want    any
+		wantErr string
+	}{
+		{name: "match", exp: `path =~ '^/api/v\d+/users/\d+$'`, want: true},
+		{name: "not match", exp: "level !~ '^(DEBUG|INFO)$'", want: true},
+		{name: "precedence", exp: "level =~ 'ERR' && path !~ 'admin'", want: true},
+		{name: "dynamic pattern", exp: "path =~ rule", want: true},
+		{name: "folded pattern", exp: "path =~ '^/api/' + 'v1'", want: true},
+		{name: "literal operands", exp: "'abc' =~ 'b'", want: true},
+		{name: "invalid dynamic pattern", exp: "path =~ bad", wantErr: `invalid regular expression "["`},
+		{name: "not a string", exp: "code =~ '5..'", wantErr: "invalid operation int =~ string"},
+		{name: "pattern not a string", exp: "path !~ code", wantErr: "invalid operation string !~ int"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkExecute(t, tt.exp, tt.want, tt.wantErr, params, nil)
+		})
+	}
+}
+
+func TestMatch_InvalidPattern(t *testing.T) {
+	_, err := NewExpression("level =~ 'a(b'", true, nil)
+	var se *SyntaxError
+	if !errors.As(err, &se) {
+		t.Fatalf("NewExpression() error = %v, want *SyntaxError", err)
+	}
+	want := "syntax: line 1, column 10 (offset 9): invalid regular expression \"a(b\": error parsing regexp: missing closing ): `a(b`"
+	if err.Error() != want {
+		t.Errorf("NewExpression() error = %v, want %s", err, want)
+	}
+	// 未开启优化时字面量同样预编译
+	if _, err = NewExpression("level =~ '['", true, nil, WithoutOptimization()); !errors.As(err, &se) {
+		t.Errorf("NewExpression() error = %v, want *SyntaxError", err)
+	}
+}
+
+func TestMatch_Precompiled(t *testing.T) {
+	e, err := NewExpression("level =~ '^E'", true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var re *regexp.Regexp
+	for _, c := range e.prog.consts {
+		if r, ok := c.ref.(*regexp.Regexp); ok {
+			re = r
+		}
+	}
+	if re == nil || re.String() != "^E" {
+		t.Fatalf("consts = %v, want precompiled pattern", e.prog.consts)
+	}
+}
+
+func TestLexer_Match(t *testing.T) {
+	tests := []struct {
+		exp  string
+		want []any
+	}{
+		{exp: "a=~'x'", want: []any{"a", "=~", "x"}},
+		{exp: "a !~ b", want: []any{"a", "!~", "b"}},
+		{exp: "! ~a", want: []any{"!", "~", "a"}},
+		{exp: `'\d+\'s'`, want: []any{`\d+'s`}},
+	}
+	for _, tt := range tests {
+		l := newLexer(tt.exp)
+		if err := l.Parse(nil); err != nil {
+			t.Fatalf("Parse(%s) error = %v", tt.exp, err)
+		}
+		var got []any
+		for _, token := range l.Tokens {
+			got = append(got, token.Raw)
+		}
+		if len(got) != len(tt.want) {
+			t.Fatalf("Parse(%s) = %v, want %v", tt.exp, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Parse(%s) = %v, want %v", tt.exp, got, tt.want)
+			}
+		}
+	}
+}
+
+func BenchmarkMatch(b *testing.B) {
+	params := map[string]any{"path": "/api/v1/users/42", "rule": `^/api/v\d+/users/\d+$`}
+	for _, exp := range []string{`path =~ '^/api/v\d+/users/\d+$'`, "path =~ rule"} {
+		e, err := NewExpression(exp, true, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(exp, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := e.Execute(params); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
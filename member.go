@@ -0,0 +1,144 @@
+package goexpression
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// index 成员访问与索引, a.b 等价于 a['b']
+//   - map: 按 key 取值, key 不存在时返回错误
+//   - []any、切片、数组: 按整数下标取值, 越界时返回错误
+//   - 结构体: 按字段名取值, 字段可以用 `expr:"name"` 标签指定名称, `expr:"-"` 忽略该字段
+//   - 指针: 自动解引用
+//
+// key 不存在、下标越界与对 nil 取值返回 *notFoundError, ?? 的左侧与可选链中视为 nil
+func index(obj, key any) (any, error) {
+	switch o := obj.(type) {
+	case map[string]any:
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("execute: map key must be string, got %T", key)
+		}
+		v, ok := o[name]
+		if !ok {
+			return nil, notFound("execute: key %q not found in map", name)
+		}
+		return v, nil
+	case []any:
+		i, err := sliceIndex(key, len(o))
+		if err != nil {
+			return nil, err
+		}
+		return o[i], nil
+	case nil:
+		return nil, notFound("execute: cannot index nil with %v", key)
+	}
+	return reflectIndex(reflect.ValueOf(obj), key)
+}
+
+// lookup 成员访问与索引, lenient 时成员不存在的结果为 nil
+func lookup(obj, key any, lenient bool) (any, error) {
+	v, err := index(obj, key)
+	if _, ok := err.(*notFoundError); ok && lenient {
+		return nil, nil
+	}
+	return v, err
+}
+
+// notFoundError 成员不存在, 错误信息与普通的执行错误相同
+type notFoundError struct {
+	msg string
+}
+
+func notFound(format string, args ...any) error {
+	return &notFoundError{msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *notFoundError) Error() string { return e.msg }
+
+func reflectIndex(rv reflect.Value, key any) (any, error) {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, notFound("execute: cannot index nil %s with %v", rv.Type(), key)
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		k := reflect.ValueOf(key)
+		if n, ok := asNumber(key); ok {
+			k = reflect.ValueOf(n)
+		}
+		if !k.IsValid() || !k.Type().ConvertibleTo(rv.Type().Key()) {
+			return nil, fmt.Errorf("execute: invalid key %v(%T) for %s", key, key, rv.Type())
+		}
+		v := rv.MapIndex(k.Convert(rv.Type().Key()))
+		if !v.IsValid() {
+			return nil, notFound("execute: key %v not found in %s", key, rv.Type())
+		}
+		return v.Interface(), nil
+	case reflect.Slice, reflect.Array:
+		i, err := sliceIndex(key, rv.Len())
+		if err != nil {
+			return nil, err
+		}
+		return rv.Index(i).Interface(), nil
+	case reflect.Struct:
+		name, ok := key.(string)
+		if !ok {
+			return nil, fmt.Errorf("execute: field name must be string, got %T", key)
+		}
+		field, ok := structFields(rv.Type())[name]
+		if !ok {
+			return nil, fmt.Errorf("execute: field %q not found in %s", name, rv.Type())
+		}
+		return rv.Field(field).Interface(), nil
+	default:
+		return nil, fmt.Errorf("execute: cannot index %s with %v", rv.Type(), key)
+	}
+}
+
+// sliceIndex 检查下标类型与范围
+func sliceIndex(key any, length int) (int, error) {
+	i, ok := key.(int64)
+	if !ok {
+		f, isFloat := toFloat64(key)
+		if !isFloat || f != float64(int64(f)) {
+			return 0, fmt.Errorf("execute: index must be integer, got %v(%T)", key, key)
+		}
+		i = int64(f)
+	}
+	if i < 0 || i >= int64(length) {
+		return 0, notFound("execute: index %d out of range [0, %d)", i, length)
+	}
+	return int(i), nil
+}
+
+// structFieldCache 结构体类型 => 可访问字段名 => 字段下标
+var structFieldCache sync.Map
+
+func structFields(typ reflect.Type) map[string]int {
+	if fields, ok := structFieldCache.Load(typ); ok {
+		return fields.(map[string]int)
+	}
+	fields := make(map[string]int, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("expr"); ok {
+			if tag, _, _ = strings.Cut(tag, ","); tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+		}
+		fields[name] = i
+	}
+	structFieldCache.Store(typ, fields)
+	return fields
+}
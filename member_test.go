@@ -0,0 +1,126 @@
+package goexpression
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testProfile struct {
+	Age      int
+	Nickname string `expr:"nick"`
+	Secret   string `expr:"-"`
+	internal int
+}
+
+type testUser struct {
+	Name    string
+	Profile *testProfile
+	Tags    []string
+	Scores  map[string]float64
+}
+
+func TestIndex(t *testing.T) {
+	params := map[string]any{
+		"user": &testUser{
+			Name:    "go",
+			Profile: &testProfile{Age: 18, Nickname: "gopher", Secret: "x"},
+			Tags:    []string{"a", "b", "c"},
+			Scores:  map[string]float64{"math": 99.5},
+		},
+		"order": map[string]any{
+			"items": []any{
+				map[string]any{"price": 10.5, "in": true},
+				map[string]any{"price": int64(20)},
+			},
+		},
+		"tags":  []any{"x", "y", "z"},
+		"ids":   map[int64]string{1: "one"},
+		"empty": (*testUser)(nil),
+	}
+	functions := map[string]Function{
+		"len": func(params ...any) (any, error) {
+			return int64(reflect.ValueOf(params[0]).Len()), nil
+		},
+		"first": func(params ...any) (any, error) {
+			return params[0].([]any)[0], nil
+		},
+		"argc": func(params ...any) (any, error) {
+			return int64(len(params)), nil
+		},
+	}
+	tests := []struct {
+		name    string
+		exp     string
+		want    any
+		wantErr bool
+	}{
+		{name: "struct pointer", exp: "user.profile", wantErr: true},
+		{name: "nested struct", exp: "user.Profile.Age + 1", want: int64(19)},
+		{name: "struct tag", exp: "user.Profile.nick", want: "gopher"},
+		{name: "struct tag hides name", exp: "user.Profile.Nickname", wantErr: true},
+		{name: "struct ignored field", exp: "user.Profile.Secret", wantErr: true},
+		{name: "struct unexported field", exp: "user.Profile.internal", wantErr: true},
+		{name: "typed slice", exp: "user.Tags[1] == 'b' && 'c' in user.Tags", want: true},
+		{name: "typed map", exp: "user.Scores['math'] > 99", want: true},
+		{name: "map string key", exp: "order['items'][0].price", want: 10.5},
+		{name: "keyword member", exp: "order.items[0].in", want: true},
+		{name: "map dynamic key", exp: "order.items[1]['pri' + 'ce'] * 2", want: int64(40)},
+		{name: "computed index", exp: "tags[len(tags) - 1]", want: "z"},
+		{name: "float index", exp: "tags[1.0]", want: "y"},
+		{name: "func result", exp: "first(order.items).price", want: 10.5},
+		{name: "list literal argument", exp: "argc([1, 2], 3) * 10 + argc([1, 2]) + argc(tags, 3) * 100", want: int64(221)},
+		{name: "list literal element", exp: "len([1, 2]) + first([5]) + [1, 2][1]", want: int64(9)},
+		{name: "paren", exp: "(order.items)[1].price", want: int64(20)},
+		{name: "int key map", exp: "ids[1]", want: "one"},
+		{name: "unary", exp: "-order.items[1].price", want: int64(-20)},
+		{name: "missing key", exp: "order.total", wantErr: true},
+		{name: "out of range", exp: "tags[3]", wantErr: true},
+		{name: "negative index", exp: "tags[-1]", wantErr: true},
+		{name: "fraction index", exp: "tags[0.5]", wantErr: true},
+		{name: "nil pointer", exp: "empty.Name", wantErr: true},
+		{name: "index number", exp: "user.Profile.Age.x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExpression(tt.exp, true, functions)
+			if err != nil {
+				t.Fatalf("NewExpression() error = %v", err)
+			}
+			got, err := e.Execute(params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Execute() got = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLexer_Dot(t *testing.T) {
+	tests := []struct {
+		exp     string
+		want    []TokenKind
+		wantErr bool
+	}{
+		{exp: ".5 + a.b", want: []TokenKind{FloatLit, Op, Var, Dot, Var}},
+		{exp: "a[0].in", want: []TokenKind{Var, Lbrack, IntLit, Rbrack, Dot, Var}},
+		{exp: "fn().t", want: []TokenKind{Func, Lparen, Rparen, Dot, Var}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.exp, func(t *testing.T) {
+			l := newLexer(tt.exp)
+			err := l.Parse(map[string]Function{"fn": nil})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []TokenKind
+			for _, token := range l.Tokens {
+				got = append(got, token.Type)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
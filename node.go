@@ -0,0 +1,99 @@
+package goexpression
+
+// NodeKind 语法树节点类型
+type NodeKind int
+
+const (
+	OpNode      NodeKind = iota // 操作符, Children 为操作数
+	LitNode                     // 字面量, Value 为字面量值
+	VarNode                     // 变量, Name 为变量名
+	FuncNode                    // 函数调用, Name 为函数名, Children 为参数
+	ListNode                    // 集合, Children 为元素
+	IndexNode                   // 成员访问与索引, Children 为对象与 key, 可选链 a?.b 的 Value 为 true
+	BadNode                     // 无法解析的部分
+	AssignNode                  // 赋值, Op 为复合赋值的运算符(= 为 NotOperator), Children 为变量与值
+	SeqNode                     // ';' 分隔的语句, Children 为各语句, 结果为最后一条语句的值
+	LambdaNode                  // lambda, Value 为参数名 []string, Children 为函数体
+	TernaryNode                 // 三元表达式, Children 为条件与两个分支
+)
+
+// Node 语法树节点, 供编辑器等工具使用, 修改 Node 不影响表达式的执行
+type Node struct {
+	Kind     NodeKind
+	Op       Operator // OpNode 的操作符
+	Value    any      // LitNode 的值, LambdaNode 的参数名, 可选链 IndexNode 为 true
+	Name     string   // VarNode 的变量名或 FuncNode 的函数名
+	Span     Span     // 节点对应的源码区间
+	Children []*Node
+}
+
+// ParseAST 以错误恢复模式解析表达式, 返回语法树与全部语法错误
+// 有语法错误时语法树不完整, 无法解析的部分为 BadNode; 语法树不做编译期优化, 与源码一一对应
+// opts 与 NewExpression 相同, WithStdlib、WithContextFunctions 注册的函数同样可以识别; WithSchema 与 WithLimits 不生效
+func ParseAST(exp string, functions map[string]Function, opts ...Option) (*Node, ErrorList) {
+	cfg := newConfig(opts)
+	cfg.disableOptimization, cfg.allErrors = true, true
+	cfg.schema, cfg.limits = nil, Limits{}
+	p := newParse(exp, cfg)
+	root, err := p.OnceParse(functions)
+	errs, _ := err.(ErrorList)
+	return newNode(root), errs
+}
+
+func newNode(node *astNode) *Node {
+	if node == nil {
+		return nil
+	}
+	ret := &Node{Op: node.op, Span: node.span}
+	switch node.kind {
+	case opNode:
+		ret.Kind = OpNode
+		ret.Children = []*Node{newNode(node.left)}
+		if node.op.IsBinaryOperator() {
+			ret.Children = append(ret.Children, newNode(node.right))
+		}
+	case litNode:
+		ret.Kind, ret.Value = LitNode, node.value
+	case varNode:
+		ret.Kind, ret.Name = VarNode, node.value.(string)
+	case funcNode:
+		ret.Kind, ret.Name = FuncNode, node.name
+		if node.right != nil {
+			ret.Children = newNodes(commaItems(node.right))
+		}
+	case builtinNode:
+		ret.Kind, ret.Name, ret.Children = FuncNode, node.name, newNodes(builtinArgs(node))
+	case lambdaNode:
+		ret.Kind, ret.Value, ret.Children = LambdaNode, node.value, []*Node{newNode(node.left)}
+	case ternaryNode:
+		then, els := ternaryBranches(node)
+		ret.Kind, ret.Children = TernaryNode, []*Node{newNode(node.left), newNode(then), newNode(els)}
+	case listNode:
+		ret.Kind, ret.Children = ListNode, newNodes(commaItems(node.left))
+	case indexNode:
+		ret.Kind, ret.Children = IndexNode, []*Node{newNode(node.left), newNode(node.right)}
+		if node.value == true {
+			ret.Value = true
+		}
+	case assignNode:
+		ret.Kind, ret.Children = AssignNode, []*Node{newNode(node.left), newNode(node.right)}
+	case seqNode:
+		// a; b; c 解析为 ((a; b); c), 展开为三条语句
+		ret.Kind = SeqNode
+		for ; node.kind == seqNode; node = node.left {
+			ret.Children = append([]*Node{newNode(node.right)}, ret.Children...)
+		}
+		ret.Children = append([]*Node{newNode(node)}, ret.Children...)
+	default:
+		ret.Kind = BadNode
+	}
+	return ret
+}
+
+func newNodes(nodes []*astNode) []*Node {
+	ret := make([]*Node, len(nodes))
+	for i, node := range nodes {
+		ret[i] = newNode(node)
+	}
+	return ret
+}
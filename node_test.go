@@ -0,0 +1,174 @@
+package goexpression
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// dumpNode 语法树的简洁文本形式, 如 (a + [1, 2])
+func dumpNode(n *Node) string {
+	if n == nil {
+		return "<nil>"
+	}
+	children := make([]string, len(n.Children))
+	for i, c := range n.Children {
+		children[i] = dumpNode(c)
+	}
+	switch n.Kind {
+	case OpNode:
+		if len(children) == 1 {
+			return fmt.Sprintf("(%v%s)", n.Op, children[0])
+		}
+		return fmt.Sprintf("(%s %v %s)", children[0], n.Op, children[1])
+	case LitNode:
+		if s, ok := n.Value.(string); ok {
+			return "'" + s + "'"
+		}
+		return fmt.Sprint(n.Value)
+	case VarNode:
+		return n.Name
+	case FuncNode:
+		return n.Name + "(" + strings.Join(children, ", ") + ")"
+	case ListNode:
+		return "[" + strings.Join(children, ", ") + "]"
+	case IndexNode:
+		return children[0] + "[" + children[1] + "]"
+	case AssignNode:
+		op := "="
+		if n.Op != NotOperator {
+			op = n.Op.String() + op
+		}
+		return fmt.Sprintf("(%s %s %s)", children[0], op, children[1])
+	case SeqNode:
+		return strings.Join(children, "; ")
+	case LambdaNode:
+		return "(" + strings.Join(n.Value.([]string), ", ") + ") => " + children[0]
+	default:
+		return "BAD"
+	}
+}
+
+func TestParseAST(t *testing.T) {
+	functions := map[string]Function{"fn": nil}
+	tests := []struct {
+		name     string
+		exp      string
+		wantTree string
+		wantErrs []string
+	}{
+		{name: "no error", exp: "a.b[0] + fn(1, 'x') > 2 && c in [1, 2]", wantTree: "(((a['b'][0] + fn(1, 'x')) > 2) && (c in [1, 2]))"},
+		{
+			name: "unbalanced brackets", exp: "(a + [1, 2) > 1 && (b",
+			wantTree: "(((a + [1, 2]) > 1) && b)",
+			wantErrs: []string{
+				"syntax: line 1, column 1 (offset 0): ( lack of )",
+				"syntax: line 1, column 6 (offset 5): [ lack of ]",
+				"syntax: line 1, column 11 (offset 10): unmatched )",
+				"syntax: line 1, column 20 (offset 19): ( lack of )",
+			},
+		},
+		{
+			name: "illegal transitions", exp: "a + * 2 || b c == 1",
+			wantTree: "((a + (BAD * 2)) || b)",
+			wantErrs: []string{
+				"syntax: line 1, column 5 (offset 4): parse unaryExpr illegal operator *",
+				"syntax: line 1, column 14 (offset 13): illegal c after b",
+			},
+		},
+		{
+			name: "unknown functions", exp: "g(a, 1) > 0 && fn(b) || h()",
+			wantTree: "(((g(a, 1) > 0) && fn(b)) || h())",
+			wantErrs: []string{
+				"syntax: line 1, column 1 (offset 0): unknown function g",
+				"syntax: line 1, column 25 (offset 24): unknown function h",
+			},
+		},
+		{
+			name: "lexer errors", exp: "a = 1 && b # 2 && '中",
+			wantTree: "(a = (1 && b))",
+			wantErrs: []string{
+				"lexer: line 1, column 12 (offset 11): character '#' illegal",
+				"syntax: line 1, column 14 (offset 13): illegal 2 after b",
+				"lexer: line 1, column 19 (offset 18): string missing right '\\''",
+			},
+		},
+		{name: "statements", exp: "a += 1; b = c = 2; a + b;", wantTree: "(a += 1); (b = (c = 2)); (a + b)"},
+		{
+			name: "cannot assign", exp: "a.b = 1; (a + 1) -= 2",
+			wantTree: "(BAD = 1); (BAD -= 2)",
+			wantErrs: []string{
+				"syntax: line 1, column 1 (offset 0): cannot assign to a.b",
+				"syntax: line 1, column 11 (offset 10): cannot assign to a + 1",
+			},
+		},
+		{
+			name: "lambdas", exp: "reduce(filter(xs, x => x > 1), (s, x) => s + x, 0) > 1 && (y => y)",
+			wantTree: "((reduce(filter(xs, (x) => (x > 1)), (s, x) => (s + x), 0) > 1) && BAD)",
+			wantErrs: []string{"syntax: line 1, column 60 (offset 59): lambda can only be an argument of built-in collection functions"},
+		},
+		{
+			name: "premature end", exp: "a > 1 &&",
+			wantTree: "((a > 1) && BAD)",
+			wantErrs: []string{"syntax: line 1, column 7 (offset 6): && can't end as an expression", "syntax: line 1, column 9 (offset 8): need unaryExpr, expression premature end"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, errs := ParseAST(tt.exp, functions)
+			var got []string
+			for _, err := range errs {
+				got = append(got, err.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.wantErrs, "\n") {
+				t.Errorf("ParseAST() errors = \n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.wantErrs, "\n"))
+			}
+			if tree := dumpNode(root); tree != tt.wantTree {
+				t.Errorf("ParseAST() tree = %s, want %s", tree, tt.wantTree)
+			}
+		})
+	}
+}
+
+func TestParseAST_Options(t *testing.T) {
+	exp := "len(a) > 1 && now() > b && lookup(a)"
+	contextFunctions := map[string]ContextFunction{"lookup": func(ctx context.Context, params ...any) (any, error) { return nil, nil }}
+	if _, errs := ParseAST(exp, nil); len(errs) != 3 {
+		t.Errorf("ParseAST() without options errors = %v, want 3 unknown functions", errs)
+	}
+	root, errs := ParseAST(exp, nil, WithStdlib(), WithContextFunctions(contextFunctions), WithSchema(Schema{}))
+	if len(errs) != 0 {
+		t.Fatalf("ParseAST() errors = %v", errs)
+	}
+	if tree := dumpNode(root); tree != "(((len(a) > 1) && (now() > b)) && lookup(a))" {
+		t.Errorf("ParseAST() tree = %s", tree)
+	}
+}
+
+func TestWithAllErrors(t *testing.T) {
+	_, err := NewExpression("(a + ) > 1 && b(1)", true, nil, WithAllErrors())
+	var errs ErrorList
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("NewExpression() error = %v, want ErrorList of 2 errors", err)
+	}
+	if want := "syntax: line 1, column 6 (offset 5): illegal ) after + (and 1 more errors)"; err.Error() != want {
+		t.Errorf("NewExpression() error = %s, want %s", err, want)
+	}
+
+	// 默认只返回第一个错误
+	_, err = NewExpression("(a + ) > 1 && b(1)", true, nil)
+	var se *SyntaxError
+	if !errors.As(err, &se) {
+		t.Fatalf("NewExpression() error = %v, want *SyntaxError", err)
+	}
+
+	e, err := NewExpression("a + 1 > 2", true, nil, WithAllErrors())
+	if err != nil {
+		t.Fatalf("NewExpression() error = %v", err)
+	}
+	if ret, err := e.Bool(map[string]any{"a": 2}); err != nil || !ret {
+		t.Errorf("Bool() = %v, %v, want true", ret, err)
+	}
+}
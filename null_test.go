@@ -0,0 +1,178 @@
+package goexpression
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type nullUser struct {
+	Name    string
+	Profile *nullUser
+}
+
+func TestExecute_Null(t *testing.T) {
+	var calls int
+	functions := map[string]Function{
+		"none": func(params ...any) (any, error) { return nil, nil },
+		"count": func(params ...any) (any, error) {
+			calls++
+			return int64(calls), nil
+		},
+	}
+	params := map[string]any{
+		"user":  map[string]any{"name": "alice", "age": 30, "address": nil, "tags": []any{"a"}},
+		"empty": nil,
+		"n":     0,
+		"s":     "",
+		"p":     &nullUser{Name: "bob"},
+	}
+	tests := []struct {
+		name      string
+		exp       string
+		want      any
+		wantCalls int
+		wantErr   string
+	}{
+		{name: "null literal", exp: "null", want: nil},
+		{name: "nil literal", exp: "nil == NULL", want: true},
+		{name: "compare with null", exp: "empty == null && user != nil && n != null", want: true},
+		{name: "null in list", exp: "null in [1, null]", want: true},
+		{name: "coalesce nil", exp: "empty ?? 'x'", want: "x"},
+		{name: "coalesce keeps zero values", exp: "[n ?? 1, s ?? 'x']", want: []any{int64(0), ""}},
+		{name: "coalesce missing variable", exp: "missing ?? 1", want: int64(1)},
+		{name: "coalesce missing key", exp: "user.email ?? 'none'", want: "none"},
+		{name: "coalesce missing chain", exp: "user.address.city ?? user.missing.city ?? 'none'", want: "none"},
+		{name: "coalesce out of range", exp: "user.tags[1] ?? user.tags[0]", want: "a"},
+		{name: "coalesce nil pointer", exp: "p.Profile.Name ?? p.Name", want: "bob"},
+		{name: "coalesce function", exp: "none() ?? 2", want: int64(2)},
+		{name: "coalesce right not evaluated", exp: "user.name ?? count()", want: "alice"},
+		{name: "coalesce right evaluated", exp: "user.email ?? count()", want: int64(1), wantCalls: 1},
+		{name: "coalesce precedence", exp: "user.age ?? 0 >= 18 && missing ?? 1 + 1 == 2", want: true},
+		{name: "coalesce chain", exp: "empty ?? missing ?? 'z'", want: "z"},
+		{name: "optional member", exp: "empty?.name", want: nil},
+		{name: "optional short circuits chain", exp: "empty?.a.b[0]", want: nil},
+		{name: "optional missing key", exp: "user?.email", want: nil},
+		{name: "optional index", exp: "user.tags?.[3] == null && user?.['name'] == 'alice'", want: true},
+		{name: "optional struct", exp: "p.Profile?.Name", want: nil},
+		{name: "optional keyword field", exp: "user?.null ?? 1", want: int64(1)},
+		{name: "optional with ternary", exp: "true ? .5 : 1", want: 0.5},
+		{name: "missing variable", exp: "missing + 1", wantErr: "missing param not in the passed parameter list"},
+		{name: "missing key", exp: "user.email", wantErr: `key "email" not found in map`},
+		{name: "index nil", exp: "user.address.city", wantErr: "cannot index nil with city"},
+		{name: "optional only covers its link", exp: "user?.address.city", wantErr: "cannot index nil with city"},
+		{name: "compare nil", exp: "empty < 1", wantErr: "invalid operation nil < int"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			e, err := NewExpression(tt.exp, true, functions)
+			if err != nil {
+				t.Fatalf("NewExpression() error = %v", err)
+			}
+			got, err := e.Execute(params)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Execute() = %v, error = %v, want %s", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) || calls != tt.wantCalls {
+				t.Errorf("Execute() = %#v, calls %d, want %#v, calls %d", got, calls, tt.want, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWithLenientLookup(t *testing.T) {
+	e, err := NewExpression("a == nil && (b ?? 2) == 2 && all([1, 2], x => c == null)", true, nil, WithLenientLookup())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := e.Execute(nil); err != nil || got != true {
+		t.Errorf("Execute() = %v, %v, want true", got, err)
+	}
+	// 成员不存在时仍返回错误
+	e, _ = NewExpression("a.b", true, nil, WithLenientLookup())
+	if _, err := e.Execute(map[string]any{"a": map[string]any{}}); err == nil {
+		t.Error("Execute() error = nil, want missing key error")
+	}
+	e, _ = NewExpression("a + 1", true, nil, WithLenientLookup())
+	var te *TypeError
+	if _, err := e.Execute(nil); !errors.As(err, &te) {
+		t.Errorf("Execute() error = %v, want *TypeError", err)
+	}
+	residual, _, err := e.PartialEval(map[string]any{"b": 1})
+	if err != nil || residual == nil {
+		t.Fatalf("PartialEval() = %v, %v", residual, err)
+	}
+	if got, err := residual.Execute(map[string]any{"a": 1}); err != nil || got != int64(2) {
+		t.Errorf("residual.Execute() = %v, %v, want 2", got, err)
+	}
+}
+
+func TestNull_Syntax(t *testing.T) {
+	tests := []struct {
+		name    string
+		exp     string
+		wantErr string
+	}{
+		{name: "optional without field", exp: "a?.", wantErr: "syntax: line 1, column 2 (offset 1): ?. can't end as an expression"},
+		{name: "optional call", exp: "a?.(b)", wantErr: "syntax: line 1, column 4 (offset 3): illegal ( after ?."},
+		{name: "ternary with number", exp: "a?.1", wantErr: "syntax: line 1, column 2 (offset 1): ? lack of :"},
+		{name: "null member", exp: "null.a", wantErr: "syntax: line 1, column 5 (offset 4): illegal . after null"},
+		{name: "coalesce without right", exp: "a ??", wantErr: "syntax: line 1, column 3 (offset 2): ?? can't end as an expression"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewExpression(tt.exp, true, nil)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("NewExpression() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNull_Compile(t *testing.T) {
+	tests := []struct {
+		exp      string
+		wantLit  bool
+		want     any
+		wantVars []string
+	}{
+		{exp: "null ?? a", wantVars: []string{"a"}},
+		{exp: "'x' ?? a", wantLit: true, want: "x"},
+		{exp: "(null)?.a ?? 3", wantLit: true, want: int64(3)},
+		{exp: "a.b ?? c", wantVars: []string{"a.b", "c"}},
+		{exp: "a?.b.c", wantVars: []string{"a.b.c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.exp, func(t *testing.T) {
+			e, err := NewExpression(tt.exp, true, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isLit := e.root.kind == litNode; isLit != tt.wantLit || isLit && e.root.value != tt.want {
+				t.Errorf("root = %+v, want literal %v", e.root, tt.want)
+			}
+			if got := e.Variables(); !tt.wantLit && !reflect.DeepEqual(got, tt.wantVars) {
+				t.Errorf("Variables() = %v, want %v", got, tt.wantVars)
+			}
+		})
+	}
+	root, _ := ParseAST("a?.b", nil)
+	if root.Kind != IndexNode || root.Value != true {
+		t.Errorf("ParseAST() = %+v, want optional IndexNode", root)
+	}
+	schema := Schema{Vars: map[string]Type{"age": TypeInt, "name": TypeString}}
+	if _, err := NewExpression("(age ?? 0) + 1 > 1 && age != null", true, nil, WithSchema(schema)); err != nil {
+		t.Errorf("NewExpression() error = %v", err)
+	}
+	if _, err := NewExpression("(name ?? 'x') + 1 > 1", true, nil, WithSchema(schema)); err == nil {
+		t.Error("NewExpression() error = nil, want type error")
+	}
+}
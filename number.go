@@ -0,0 +1,144 @@
+package goexpression
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// asNumber 将 Go 的各种数值类型统一转换为 int64 或 float64
+//   - 有符号整数与不超过 math.MaxInt64 的无符号整数转换为 int64
+//   - 更大的无符号整数转换为 float64, 不报错但只保留 53 位精度, 如 math.MaxUint64 与 math.MaxUint64-1 都转换为 2^64
+//   - float32 按其最短十进制表示转换为 float64, 如 float32(0.1) 转换为 0.1
+//   - json.Number 能解析为整数时转换为 int64, 否则转换为 float64
+//   - 底层类型为数值的自定义类型按底层类型转换, time.Duration 除外
+//
+// 非数值返回 false
+func asNumber(v any) (any, bool) {
+	switch n := v.(type) {
+	case int64, float64:
+		return v, true
+	case nil, bool, string, []any, time.Time, time.Duration:
+		return nil, false
+	case int:
+		return int64(n), true
+	case int8:
+		return int64(n), true
+	case int16:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case uint:
+		return uintToNumber(uint64(n)), true
+	case uint8:
+		return int64(n), true
+	case uint16:
+		return int64(n), true
+	case uint32:
+		return int64(n), true
+	case uint64:
+		return uintToNumber(n), true
+	case uintptr:
+		return uintToNumber(uint64(n)), true
+	case float32:
+		return float32ToFloat64(n), true
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			return i, true
+		}
+		if f, err := n.Float64(); err == nil {
+			return f, true
+		}
+		return nil, false
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return uintToNumber(rv.Uint()), true
+	case reflect.Float32:
+		return float32ToFloat64(float32(rv.Float())), true
+	case reflect.Float64:
+		return rv.Float(), true
+	}
+	return nil, false
+}
+
+// normalize 数值统一转换为 int64 或 float64, 其余值原样返回
+func normalize(v any) any {
+	if n, ok := asNumber(v); ok {
+		return n
+	}
+	return v
+}
+
+// uintToNumber 超过 math.MaxInt64 时转换为最接近的 float64, 会丢失精度
+func uintToNumber(n uint64) any {
+	if n > math.MaxInt64 {
+		return float64(n)
+	}
+	return int64(n)
+}
+
+func float32ToFloat64(f float32) float64 {
+	ret, err := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'g', -1, 32), 64)
+	if err != nil { // NaN/Inf
+		return float64(f)
+	}
+	return ret
+}
+
+// restoreKind 将运算结果转换回变量原有的数值类型, 用于 WithWriteBack 写回, 如 int 变量 ++ 后仍为 int
+//   - 整数类型只接受整数结果, 超出该类型范围时不转换
+//   - 浮点类型接受整数、浮点数与 Decimal 结果, float32 按其精度保存
+//   - json.Number 以结果的十进制文本保存
+//
+// 原值不是数值或无法转换时返回 v, 此时写回的值为 int64、float64 等执行时的类型
+func restoreKind(orig, v any) any {
+	if orig == nil || v == nil || reflect.TypeOf(orig) == reflect.TypeOf(v) {
+		return v
+	}
+	if _, ok := orig.(json.Number); ok {
+		switch n := v.(type) {
+		case int64:
+			return json.Number(strconv.FormatInt(n, 10))
+		case float64:
+			return json.Number(strconv.FormatFloat(n, 'g', -1, 64))
+		case Decimal:
+			return json.Number(n.String())
+		}
+		return v
+	}
+	if _, ok := asNumber(orig); !ok {
+		return v
+	}
+	rv := reflect.New(reflect.TypeOf(orig)).Elem()
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := v.(int64)
+		if rv.SetInt(i); !ok || rv.Int() != i {
+			return v
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := v.(int64)
+		if !ok || i < 0 {
+			return v
+		}
+		if rv.SetUint(uint64(i)); rv.Uint() != uint64(i) {
+			return v
+		}
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat64(v)
+		if !ok || rv.Kind() == reflect.Float32 && math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
+			return v
+		}
+		rv.SetFloat(f)
+	default:
+		return v
+	}
+	return rv.Interface()
+}
@@ -0,0 +1,154 @@
+package goexpression
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+)
+
+type testCount uint16
+
+func TestNumber_Params(t *testing.T) {
+	params := map[string]any{
+		"i":     int(5),
+		"i8":    int8(-3),
+		"i32":   int32(7),
+		"u8":    uint8(200),
+		"u64":   uint64(math.MaxUint64),
+		"u64m1": uint64(math.MaxUint64 - 1),
+		"u64mi": uint64(math.MaxInt64),
+		"f32":   float32(0.1),
+		"jsonI": json.Number("42"),
+		"jsonF": json.Number("1.5"),
+		"named": testCount(9),
+		"list":  []any{int(1), uint16(2), float32(2.5)},
+	}
+	functions := map[string]Function{
+		"int32": func(params ...any) (any, error) {
+			return int32(10), nil
+		},
+		"uint": func(params ...any) (any, error) {
+			return uint(11), nil
+		},
+		"double": func(params ...any) (any, error) {
+			// 参数已经转换为 int64
+			return params[0].(int64) * 2, nil
+		},
+	}
+	tests := []struct {
+		name string
+		exp  string
+		want any
+	}{
+		{name: "int equal", exp: "i == 5", want: true},
+		{name: "int add", exp: "i + 1", want: int64(6)},
+		{name: "int8", exp: "i8 * i32", want: int64(-21)},
+		{name: "uint8", exp: "u8 > 100 && u8 & 8 == 8", want: true},
+		{name: "uint64 overflow to float", exp: "u64 > 9223372036854775807", want: true},
+		{name: "uint64 max", exp: "u64", want: float64(1 << 64)},
+		{name: "uint64 precision lost", exp: "u64 == u64m1", want: true},
+		{name: "uint64 max int", exp: "u64mi", want: int64(math.MaxInt64)},
+		{name: "float32", exp: "f32 == 0.1", want: true},
+		{name: "json int", exp: "jsonI", want: int64(42)},
+		{name: "json float", exp: "jsonF * 2 == 3", want: true},
+		{name: "named", exp: "named + 1", want: int64(10)},
+		{name: "list in", exp: "2 in list && 2.5 in list && 1.0 in list", want: true},
+		{name: "func result", exp: "int32() + uint() == 21", want: true},
+		{name: "func arg", exp: "double(i)", want: int64(10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExpression(tt.exp, true, functions)
+			if err != nil {
+				t.Fatalf("NewExpression() error = %v", err)
+			}
+			got, err := e.Execute(params)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Execute() got = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumber_Literal(t *testing.T) {
+	tests := []struct {
+		exp     string
+		want    any
+		wantErr string
+	}{
+		{exp: "0xFF", want: int64(255)},
+		{exp: "0Xff", want: int64(255)},
+		{exp: "0b1010", want: int64(10)},
+		{exp: "0o755", want: int64(493)},
+		{exp: "0", want: int64(0)},
+		{exp: "1_000_000", want: int64(1000000)},
+		{exp: "0x_FF_FF", want: int64(65535)},
+		{exp: "0x7FFFFFFFFFFFFFFF", want: int64(math.MaxInt64)},
+		{exp: "0xFFFFFFFFFFFFFFFF", want: int64(-1)},
+		{exp: "0x8000000000000000 == -9223372036854775807 - 1", want: true},
+		{exp: "0b1111 & 0xFFFFFFFFFFFFFFF0", want: int64(0)},
+		{exp: "-9223372036854775808", want: int64(math.MinInt64)},
+		{exp: "1 - -9223372036854775808", want: int64(math.MinInt64 + 1)},
+		{exp: "0x1p-2", want: 0.25},
+		{exp: "0x1.8p1", want: 3.0},
+		{exp: "0X_1FP+2", want: 124.0},
+		{exp: "0x.8p1", want: 1.0},
+		{exp: "1e9", want: 1e9},
+		{exp: "2.5E-3", want: 0.0025},
+		{exp: "1e+2", want: 100.0},
+		{exp: ".5e1", want: 5.0},
+		{exp: "1.", want: 1.0},
+		{exp: "1_000.000_1", want: 1000.0001},
+		{exp: "0.5", want: 0.5},
+		{exp: "1.2.3", wantErr: "lexer: line 1, column 4 (offset 3): unexpected '.' after number 1.2"},
+		{exp: "0x1.5", wantErr: "lexer: line 1, column 6 (offset 5): hexadecimal mantissa requires a 'p' exponent"},
+		{exp: "0x1.8p1.5", wantErr: "lexer: line 1, column 8 (offset 7): unexpected '.' after number 0x1.8p1"},
+		{exp: "0x1p", wantErr: "lexer: line 1, column 5 (offset 4): exponent has no digits"},
+		{exp: "0x.p1", wantErr: "lexer: line 1, column 4 (offset 3): hexadecimal literal has no digits"},
+		{exp: "1p2", wantErr: "lexer: line 1, column 2 (offset 1): 'p' exponent requires hexadecimal mantissa"},
+		{exp: "0b1p2", wantErr: "lexer: line 1, column 4 (offset 3): 'p' exponent requires hexadecimal mantissa"},
+		{exp: "0xFFg", wantErr: "lexer: line 1, column 5 (offset 4): invalid character 'g' in hexadecimal literal"},
+		{exp: "0x1_0000_0000_0000_0000", wantErr: "lexer: line 1, column 1 (offset 0): integer 0x1_0000_0000_0000_0000 out of 64-bit range"},
+		{exp: "a - 9223372036854775808", wantErr: "lexer: line 1, column 5 (offset 4): integer 9223372036854775808 out of int64 range"},
+		{exp: "0b102", wantErr: "lexer: line 1, column 5 (offset 4): invalid digit '2' in binary literal"},
+		{exp: "0o78", wantErr: "lexer: line 1, column 4 (offset 3): invalid digit '8' in octal literal"},
+		{exp: "1__0", wantErr: "lexer: line 1, column 3 (offset 2): '_' must separate successive digits"},
+		{exp: "a + 1_", wantErr: "lexer: line 1, column 6 (offset 5): '_' must separate successive digits"},
+		{exp: "0x", wantErr: "lexer: line 1, column 3 (offset 2): hexadecimal literal has no digits"},
+		{exp: "1e", wantErr: "lexer: line 1, column 3 (offset 2): exponent has no digits"},
+		{exp: "0755", wantErr: "lexer: line 1, column 1 (offset 0): invalid leading zero in 0755, use 0o prefix for octal"},
+		{exp: "9223372036854775808", wantErr: "lexer: line 1, column 1 (offset 0): integer 9223372036854775808 out of int64 range"},
+		{exp: "1e999", wantErr: "lexer: line 1, column 1 (offset 0): number 1e999 out of float64 range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.exp, func(t *testing.T) {
+			e, err := NewExpression(tt.exp, true, nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("NewExpression() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, err := e.Execute(nil); err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Execute() = %#v, %v, want %#v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumber_LiteralDecimal(t *testing.T) {
+	e, err := NewExpression("1.5e2 + 2.5E-3 + 0x10 + 1_000.5 + 0x1p-2", true, nil, WithDecimal(4, RoundHalfUp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := e.Decimal(nil); err != nil || got.String() != "1166.7525" {
+		t.Errorf("Decimal() = %v, %v, want 1166.7525", got, err)
+	}
+}
@@ -1,8 +1,12 @@
 package goexpression
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math"
+	"reflect"
+	"time"
 )
 
 // opFunc 执行函数格式定义
@@ -17,13 +21,17 @@ var opFuncArray = [OpSize]opFunc{
 
 	andAndFunc, // &&
 
-	eqlFunc, // ==
-	neqFunc, // !=
-	lssFunc, // <
-	leqFunc, // <=
-	gtrFunc, // >
-	geqFunc, // >=
-	inFunc,  // in
+	eqlFunc,      // ==
+	neqFunc,      // !=
+	lssFunc,      // <
+	leqFunc,      // <=
+	gtrFunc,      // >
+	geqFunc,      // >=
+	inFunc,       // in
+	matchFunc,    // =~
+	notMatchFunc, // !~
+
+	coalesceFunc, // ??
 
 	addFunc, // +
 	subFunc, // -
@@ -47,67 +55,148 @@ var opFuncArray = [OpSize]opFunc{
 	bitNotFunc, // ~
 }
 
-// a ? b : c
-// a == true 时 return right(b)
+// ? 与 : 只组成三元表达式 a ? b : c, 由 ternaryNode 以跳转执行, 不会单独作为二元操作符计算
 func ternaryTFunc(left, right any, _ map[string]any) (any, error) {
-	if left.(bool) {
-		return right, nil
-	}
-	return nil, nil
+	return nil, fmt.Errorf("execute: %v can only be used in a ? b : c", TernaryT)
 }
 
-// left == nil 即 a == false。return right(c)
 func ternaryFFunc(left, right any, _ map[string]any) (any, error) {
-	if left != nil {
-		return left, nil
-	}
-	return right, nil
+	return nil, fmt.Errorf("execute: %v can only be used in a ? b : c", TernaryF)
 }
 
 func orOrFunc(left, right any, _ map[string]any) (any, error) {
-	return left.(bool) || right.(bool), nil
+	l, r, ok := boolOperands(left, right)
+	if !ok {
+		return nil, invalidOperation(OrOr, left, right)
+	}
+	return l || r, nil
 }
 
 func andAndFunc(left, right any, _ map[string]any) (any, error) {
-	return left.(bool) && right.(bool), nil
+	l, r, ok := boolOperands(left, right)
+	if !ok {
+		return nil, invalidOperation(AndAnd, left, right)
+	}
+	return l && r, nil
 }
 
-// 只支持基本类型
+// 只支持基本类型, 数值按大小比较, 如 1 == 1.0
 func eqlFunc(left, right any, _ map[string]any) (any, error) {
-	return left == right, nil
+	return equal(left, right), nil
 }
 
 func neqFunc(left, right any, _ map[string]any) (any, error) {
-	return left != right, nil
+	return !equal(left, right), nil
 }
 
-// str float64
+// str int64 float64 Decimal time duration
 func lssFunc(left, right any, _ map[string]any) (any, error) {
 	if IsString(left) && IsString(right) {
 		return left.(string) < right.(string), nil
 	}
-	return left.(float64) < right.(float64), nil
+	if l, r, ok := intOperands(left, right); ok {
+		return l < r, nil
+	}
+	if l, r, ok := decimalOperands(left, right); ok {
+		return l.Cmp(r) < 0, nil
+	}
+	if l, r, ok := floatOperands(left, right); ok {
+		return l < r, nil
+	}
+	if c, ok := compareTemporal(left, right); ok {
+		return c < 0, nil
+	}
+	return nil, invalidOperation(Lss, left, right)
 }
 
 func leqFunc(left, right any, _ map[string]any) (any, error) {
 	if IsString(left) && IsString(right) {
 		return left.(string) <= right.(string), nil
 	}
-	return left.(float64) <= right.(float64), nil
+	if l, r, ok := intOperands(left, right); ok {
+		return l <= r, nil
+	}
+	if l, r, ok := decimalOperands(left, right); ok {
+		return l.Cmp(r) <= 0, nil
+	}
+	if l, r, ok := floatOperands(left, right); ok {
+		return l <= r, nil
+	}
+	if c, ok := compareTemporal(left, right); ok {
+		return c <= 0, nil
+	}
+	return nil, invalidOperation(Leq, left, right)
 }
 
 func gtrFunc(left, right any, _ map[string]any) (any, error) {
 	if IsString(left) && IsString(right) {
 		return left.(string) > right.(string), nil
 	}
-	return left.(float64) > right.(float64), nil
+	if l, r, ok := intOperands(left, right); ok {
+		return l > r, nil
+	}
+	if l, r, ok := decimalOperands(left, right); ok {
+		return l.Cmp(r) > 0, nil
+	}
+	if l, r, ok := floatOperands(left, right); ok {
+		return l > r, nil
+	}
+	if c, ok := compareTemporal(left, right); ok {
+		return c > 0, nil
+	}
+	return nil, invalidOperation(Gtr, left, right)
 }
 
 func geqFunc(left, right any, _ map[string]any) (any, error) {
 	if IsString(left) && IsString(right) {
 		return left.(string) >= right.(string), nil
 	}
-	return left.(float64) >= right.(float64), nil
+	if l, r, ok := intOperands(left, right); ok {
+		return l >= r, nil
+	}
+	if l, r, ok := decimalOperands(left, right); ok {
+		return l.Cmp(r) >= 0, nil
+	}
+	if l, r, ok := floatOperands(left, right); ok {
+		return l >= r, nil
+	}
+	if c, ok := compareTemporal(left, right); ok {
+		return c >= 0, nil
+	}
+	return nil, invalidOperation(Geq, left, right)
+}
+
+// matchFunc 左操作数是否匹配右操作数的正则表达式, 右操作数为编译期预编译的 *regexp.Regexp 或执行时得到的字符串
+func matchFunc(left, right any, _ map[string]any) (any, error) {
+	return match(Match, left, right)
+}
+
+func notMatchFunc(left, right any, _ map[string]any) (any, error) {
+	ret, err := match(NotMatch, left, right)
+	if err != nil {
+		return nil, err
+	}
+	return !ret, nil
+}
+
+// a ?? b, a 为 nil 时返回 b, 执行时 a 不存在同样视为 nil 且 a 不为 nil 时不计算 b
+func coalesceFunc(left, right any, _ map[string]any) (any, error) {
+	if left != nil {
+		return left, nil
+	}
+	return right, nil
+}
+
+func match(op Operator, left, right any) (bool, error) {
+	s, ok := left.(string)
+	if !ok {
+		return false, invalidOperation(op, left, right)
+	}
+	re, err := pattern(op, left, right)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(s), nil
 }
 
 func inFunc(left, right any, _ map[string]any) (any, error) {
@@ -117,115 +206,378 @@ func inFunc(left, right any, _ map[string]any) (any, error) {
 	switch right.(type) {
 	case []any:
 		for _, v := range right.([]any) {
-			if v == left {
+			if equal(v, left) {
 				return true, nil
 			}
 		}
 		return false, nil
 	default:
-		return left == right, nil
+		// 参数或成员访问得到的 []string、[]int 等切片
+		if rv := reflect.ValueOf(right); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+			for i := 0; i < rv.Len(); i++ {
+				if equal(rv.Index(i).Interface(), left) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return equal(left, right), nil
 	}
 }
 
+// 两个操作数均为 int64 时按 Go 的整数语义运算, 否则提升为 float64 运算
 func addFunc(left, right any, _ map[string]any) (any, error) {
 	if IsString(left) && IsString(right) {
 		return left.(string) + right.(string), nil
 	}
-	return left.(float64) + right.(float64), nil
+	if l, r, ok := intOperands(left, right); ok {
+		return l + r, nil
+	}
+	if l, r, ok := decimalOperands(left, right); ok {
+		return l.add(r), nil
+	}
+	if l, r, ok := floatOperands(left, right); ok {
+		return l + r, nil
+	}
+	if ret, ok := addTemporal(left, right); ok {
+		return ret, nil
+	}
+	return nil, invalidOperation(Add, left, right)
 }
 
 func subFunc(left, right any, _ map[string]any) (any, error) {
-	return left.(float64) - right.(float64), nil
+	if l, r, ok := intOperands(left, right); ok {
+		return l - r, nil
+	}
+	if l, r, ok := decimalOperands(left, right); ok {
+		return l.sub(r), nil
+	}
+	if l, r, ok := floatOperands(left, right); ok {
+		return l - r, nil
+	}
+	if ret, ok := subTemporal(left, right); ok {
+		return ret, nil
+	}
+	return nil, invalidOperation(Sub, left, right)
 }
 
-func orFunc(left, right any, _ map[string]any) (any, error) {
-	return float64(int64(left.(float64)) | int64(right.(float64))), nil
+func mulFunc(left, right any, _ map[string]any) (any, error) {
+	if l, r, ok := intOperands(left, right); ok {
+		return l * r, nil
+	}
+	if l, r, ok := decimalOperands(left, right); ok {
+		return l.mul(r), nil
+	}
+	if l, r, ok := floatOperands(left, right); ok {
+		return l * r, nil
+	}
+	if ret, ok, err := mulDuration(left, right); ok {
+		return ret, err
+	}
+	return nil, invalidOperation(Mul, left, right)
 }
 
-func xorFunc(left, right any, _ map[string]any) (any, error) {
-	return float64(int64(left.(float64)) ^ int64(right.(float64))), nil
+func divFunc(left, right any, _ map[string]any) (any, error) {
+	if l, r, ok := intOperands(left, right); ok {
+		if r == 0 {
+			return nil, errIntegerDivideByZero
+		}
+		return l / r, nil
+	}
+	if l, r, ok := decimalOperands(left, right); ok {
+		return l.quo(r, defaultDecimalContext)
+	}
+	if l, r, ok := floatOperands(left, right); ok {
+		return l / r, nil
+	}
+	if ret, ok, err := divDuration(left, right); ok {
+		return ret, err
+	}
+	return nil, invalidOperation(Div, left, right)
 }
 
-func mulFunc(left, right any, _ map[string]any) (any, error) {
-	return left.(float64) * right.(float64), nil
+func remFunc(left, right any, _ map[string]any) (any, error) {
+	if l, r, ok := intOperands(left, right); ok {
+		if r == 0 {
+			return nil, errIntegerDivideByZero
+		}
+		return l % r, nil
+	}
+	if l, r, ok := decimalOperands(left, right); ok {
+		return l.rem(r)
+	}
+	if l, r, ok := floatOperands(left, right); ok {
+		return math.Mod(l, r), nil
+	}
+	return nil, invalidOperation(Rem, left, right)
 }
 
-func divFunc(left, right any, _ map[string]any) (any, error) {
-	return left.(float64) / right.(float64), nil
+// 位运算结果为 int64, float64 操作数截断为 int64
+func orFunc(left, right any, _ map[string]any) (any, error) {
+	if l, r, ok := bitOperands(left, right); ok {
+		return l | r, nil
+	}
+	return nil, invalidOperation(Or, left, right)
 }
 
-func remFunc(left, right any, _ map[string]any) (any, error) {
-	return math.Mod(left.(float64), right.(float64)), nil
+func xorFunc(left, right any, _ map[string]any) (any, error) {
+	if l, r, ok := bitOperands(left, right); ok {
+		return l ^ r, nil
+	}
+	return nil, invalidOperation(Xor, left, right)
 }
 
 func andFunc(left, right any, _ map[string]any) (any, error) {
-	return float64(int64(left.(float64)) & int64(right.(float64))), nil
+	if l, r, ok := bitOperands(left, right); ok {
+		return l & r, nil
+	}
+	return nil, invalidOperation(And, left, right)
 }
 
 func andNotFunc(left, right any, _ map[string]any) (any, error) {
-	return float64(int64(left.(float64)) &^ int64(right.(float64))), nil
+	if l, r, ok := bitOperands(left, right); ok {
+		return l &^ r, nil
+	}
+	return nil, invalidOperation(AndNot, left, right)
 }
 
 func shlFunc(left, right any, _ map[string]any) (any, error) {
-	return float64(int64(left.(float64)) << int64(right.(float64))), nil
+	if l, r, ok := bitOperands(left, right); ok {
+		if r < 0 {
+			return nil, errNegativeShift
+		}
+		return l << r, nil
+	}
+	return nil, invalidOperation(Shl, left, right)
 }
 
 func shrFunc(left, right any, _ map[string]any) (any, error) {
-	return float64(int64(left.(float64)) >> int64(right.(float64))), nil
+	if l, r, ok := bitOperands(left, right); ok {
+		if r < 0 {
+			return nil, errNegativeShift
+		}
+		return l >> r, nil
+	}
+	return nil, invalidOperation(Shr, left, right)
 }
 
+// 整数的非负整数次幂结果为 int64, 含 Decimal 时为 Decimal, 其余情况为 float64
 func exponentFunc(left, right any, _ map[string]any) (any, error) {
-	return math.Pow(left.(float64), right.(float64)), nil
+	if l, r, ok := intOperands(left, right); ok && r >= 0 {
+		return intPow(l, r), nil
+	}
+	if l, r, ok := decimalOperands(left, right); ok {
+		return decimalPow(l, r), nil
+	}
+	if l, r, ok := floatOperands(left, right); ok {
+		return math.Pow(l, r), nil
+	}
+	return nil, invalidOperation(Exponent, left, right)
 }
 
 func addAddFunc(left, _ any, _ map[string]any) (any, error) {
-	return left.(float64) + 1, nil
+	switch l := left.(type) {
+	case int64:
+		return l + 1, nil
+	case float64:
+		return l + 1, nil
+	case Decimal:
+		return l.add(NewDecimal(1, 0)), nil
+	}
+	return nil, invalidOperation(AddAdd, left, nil)
 }
 
 func subSubFunc(left, _ any, _ map[string]any) (any, error) {
-	return left.(float64) - 1, nil
+	switch l := left.(type) {
+	case int64:
+		return l - 1, nil
+	case float64:
+		return l - 1, nil
+	case Decimal:
+		return l.sub(NewDecimal(1, 0)), nil
+	}
+	return nil, invalidOperation(SubSub, left, nil)
 }
 
 func minusFunc(left, _ any, _ map[string]any) (any, error) {
-	return -left.(float64), nil
+	switch l := left.(type) {
+	case int64:
+		return -l, nil
+	case float64:
+		return -l, nil
+	case Decimal:
+		return l.neg(), nil
+	case time.Duration:
+		return -l, nil
+	}
+	return nil, invalidOperation(Minus, left, nil)
 }
 
 func notFunc(left, _ any, _ map[string]any) (any, error) {
-	return !left.(bool), nil
+	l, ok := left.(bool)
+	if !ok {
+		return nil, invalidOperation(Not, left, nil)
+	}
+	return !l, nil
 }
 
 func bitNotFunc(left, _ any, _ map[string]any) (any, error) {
-	return float64(^int64(left.(float64))), nil
+	if l, ok := toInt64(left); ok {
+		return ^l, nil
+	}
+	return nil, invalidOperation(BitNot, left, nil)
 }
 
-func makeLitFunc(lit any) opFunc {
-	return func(l any, r any, params map[string]any) (any, error) {
-		return lit, nil
+var (
+	errIntegerDivideByZero = errors.New("execute: integer divide by zero")
+	errNegativeShift       = errors.New("execute: negative shift amount")
+)
+
+// invalidOperation 操作数类型不支持该操作符
+func invalidOperation(op Operator, left, right any) error {
+	if !op.IsBinaryOperator() {
+		return fmt.Errorf("execute: invalid operation %v on %T", op, left)
 	}
+	return fmt.Errorf("execute: invalid operation %T %v %T", left, op, right)
 }
 
-func makeVarFunc(name string) opFunc {
-	return func(l, r any, params map[string]any) (any, error) {
-		ret, ok := params[name]
-		if !ok {
-			return nil, fmt.Errorf("execute: %s param not in the passed parameter list", name)
-		}
-		return ret, nil
+// equal 判断两个值是否相等, 数值之间按大小比较, time 之间按时刻比较
+// map、切片等不可比较的值之间视为不相等, 不会 panic
+func equal(left, right any) bool {
+	if l, r, ok := intOperands(left, right); ok {
+		return l == r
+	}
+	if l, r, ok := decimalOperands(left, right); ok {
+		return l.Cmp(r) == 0
+	}
+	if l, r, ok := floatOperands(left, right); ok {
+		return l == r
+	}
+	if left == nil || right == nil {
+		return left == right
+	}
+	if l, ok := left.(time.Time); ok {
+		r, ok := right.(time.Time)
+		return ok && l.Equal(r)
+	}
+	if typ := reflect.TypeOf(left); typ != reflect.TypeOf(right) || !typ.Comparable() {
+		return false
+	}
+	return left == right
+}
+
+// boolOperands 两个操作数均为 bool 时返回
+func boolOperands(left, right any) (bool, bool, bool) {
+	l, ok := left.(bool)
+	if !ok {
+		return false, false, false
 	}
+	r, ok := right.(bool)
+	return l, r, ok
 }
 
-func makeFuncFunc(function Function) opFunc {
-	return func(left, right any, params map[string]any) (any, error) {
-		if right == nil {
-			return function()
+// intOperands 两个操作数均为整数时返回
+func intOperands(left, right any) (int64, int64, bool) {
+	l, ok := left.(int64)
+	if !ok {
+		if l, ok = asInt64(left); !ok {
+			return 0, 0, false
 		}
-		switch right.(type) {
-		case []any:
-			return function(right.([]any)...)
-		default:
-			return function(right)
+	}
+	r, ok := right.(int64)
+	if !ok {
+		r, ok = asInt64(right)
+	}
+	return l, r, ok
+}
+
+// asInt64 Go 的其他整数类型转换为 int64
+func asInt64(v any) (int64, bool) {
+	if IsFloat64(v) {
+		return 0, false
+	}
+	n, ok := asNumber(v)
+	if !ok {
+		return 0, false
+	}
+	i, ok := n.(int64)
+	return i, ok
+}
+
+// floatOperands 两个操作数均为数值时提升为 float64 返回
+func floatOperands(left, right any) (float64, float64, bool) {
+	l, ok := toFloat64(left)
+	if !ok {
+		return 0, 0, false
+	}
+	r, ok := toFloat64(right)
+	return l, r, ok
+}
+
+// bitOperands 两个操作数均为数值时转换为 int64 返回
+func bitOperands(left, right any) (int64, int64, bool) {
+	l, ok := toInt64(left)
+	if !ok {
+		return 0, 0, false
+	}
+	r, ok := toInt64(right)
+	return l, r, ok
+}
+
+// toFloat64 数值转换为 float64, 支持 Go 的各种数值类型
+func toFloat64(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case Decimal:
+		return n.Float64(), true
+	}
+	if n, ok := asNumber(v); ok {
+		return toFloat64(n)
+	}
+	return 0, false
+}
+
+// toInt64 数值转换为 int64, 浮点数截断, 支持 Go 的各种数值类型
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case float64:
+		return int64(n), true
+	case Decimal:
+		return n.Int64()
+	}
+	if n, ok := asNumber(v); ok {
+		return toInt64(n)
+	}
+	return 0, false
+}
+
+// intPow 整数幂, 溢出时与 Go 整数乘法一样回绕
+func intPow(base, exp int64) int64 {
+	ret := int64(1)
+	for ; exp > 0; exp >>= 1 {
+		if exp&1 == 1 {
+			ret *= base
 		}
+		base *= base
+	}
+	return ret
+}
+
+// callFunction 调用函数, args 为各参数的执行结果
+func callFunction(ctx context.Context, function ContextFunction, args []slot) (any, error) {
+	if len(args) == 0 {
+		return function(ctx)
+	}
+	params := make([]any, len(args))
+	for i := range args {
+		params[i] = args[i].value()
 	}
+	return function(ctx, params...)
 }
 
 func commaFunc(left, right any, _ map[string]any) (any, error) {
@@ -0,0 +1,258 @@
+package goexpression
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOpFunc_Integer(t *testing.T) {
+	params := map[string]any{
+		"id":    int64(9007199254740993), // 2^53 + 1, float64 无法精确表示
+		"flags": int64(0b1011),
+		"x":     2.5,
+	}
+	tests := []struct {
+		name    string
+		exp     string
+		want    any
+		wantErr bool
+	}{
+		{name: "exact add", exp: "id + 0", want: int64(9007199254740993)},
+		{name: "exact compare", exp: "id == 9007199254740992", want: false},
+		{name: "bit and", exp: "flags & 8 != 0", want: true},
+		{name: "bit or shift", exp: "1 << 62 | 1", want: int64(1<<62 | 1)},
+		{name: "bit and not", exp: "flags &^ 3", want: int64(8)},
+		{name: "bit xor not", exp: "~flags ^ 1", want: int64(^0b1011 ^ 1)},
+		{name: "shr", exp: "id >> 52", want: int64(2)},
+		{name: "int div", exp: "7 / 2", want: int64(3)},
+		{name: "int rem", exp: "-7 % 3", want: int64(-1)},
+		{name: "mixed div", exp: "7 / 2.0", want: 3.5},
+		{name: "mixed add", exp: "x + 1", want: 3.5},
+		{name: "mixed compare", exp: "3 > x && 2 < x", want: true},
+		{name: "mixed equal", exp: "1 == 1.0 && 2.0 != 3", want: true},
+		{name: "mixed in", exp: "2.0 in [1, 2, 3] && 1 in [1.0]", want: true},
+		{name: "int pow", exp: "2 ** 62", want: int64(1 << 62)},
+		{name: "negative pow", exp: "2 ** -1", want: 0.5},
+		{name: "float pow", exp: "4 ** 0.5", want: 2.0},
+		{name: "unary", exp: "-(++flags) + --flags", want: int64(-1)}, // ++flags 写回 12, --flags 为 11
+		{name: "float div zero", exp: "1.0 / 0 > 1", want: true},
+		{name: "overflow wraps", exp: "9223372036854775807 + 1", want: int64(math.MinInt64)},
+		{name: "int div zero", exp: "flags / 0", wantErr: true},
+		{name: "int rem zero", exp: "flags % 0", wantErr: true},
+		{name: "negative shift", exp: "flags << -1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExpression(tt.exp, true, nil)
+			if err != nil {
+				t.Fatalf("NewExpression() error = %v", err)
+			}
+			got, err := e.Execute(params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Execute() got = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpression_Int64(t *testing.T) {
+	e, err := NewExpression("id | 1", true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := e.Int64(map[string]any{"id": int64(math.MaxInt64 - 1)})
+	if err != nil || got != math.MaxInt64 {
+		t.Errorf("Int64() got = %v, %v, want %v", got, err, int64(math.MaxInt64))
+	}
+	f, err := e.Float64(map[string]any{"id": int64(2)})
+	if err != nil || f != 3.0 {
+		t.Errorf("Float64() got = %v, %v, want 3", f, err)
+	}
+	if _, err := NewExpression("9223372036854775808", true, nil); err == nil {
+		t.Errorf("NewExpression() int64 overflow literal error = nil")
+	}
+}
+
+// valueKinds 覆盖各类参数值, 用于检查任意操作数组合都不会 panic
+var valueKinds = map[string]any{
+	"nil":    nil,
+	"bool":   true,
+	"int":    int64(3),
+	"zero":   int64(0),
+	"float":  1.5,
+	"nan":    math.NaN(),
+	"int32":  int32(-2),
+	"uint64": uint64(math.MaxUint64),
+	"string": "s",
+	"list":   []any{int64(1), "s"},
+	"ints":   []int{1, 2},
+	"map":    map[string]any{"k": int64(1)},
+	"struct": struct{ K int }{K: 1},
+	"ptr":    (*struct{ K int })(nil),
+	"func":   func() {},
+}
+
+func TestOpFunc_NoPanic(t *testing.T) {
+	for op := TernaryT; op < OpSize; op++ {
+		for ln, lv := range valueKinds {
+			for rn, rv := range valueKinds {
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Errorf("opFunc %v(%s, %s) panic: %v", op, ln, rn, r)
+						}
+					}()
+					_, _ = opFuncArray[op](normalize(lv), normalize(rv), nil)
+				}()
+			}
+		}
+	}
+}
+
+func TestExecute_NoPanic(t *testing.T) {
+	var exps []string
+	for op := TernaryT; op < OpSize; op++ {
+		switch {
+		case op == TernaryT || op == TernaryF: // 只能组成三元表达式, 见下方的 a ? b : 1
+		case op == Minus:
+			exps = append(exps, "-a")
+		case op.IsBinaryOperator():
+			exps = append(exps, "a "+op.String()+" b", "a "+op.String()+" 2", "a "+op.String()+" 'x'")
+		default:
+			exps = append(exps, op.String()+"a")
+		}
+	}
+	exps = append(exps, "a ? b : 1", "a && b || !a", "a.k[0]", "a[b]", "a in b", "a in [1, b]")
+
+	for _, needCheck := range []bool{false, true} {
+		for _, exp := range exps {
+			e, err := NewExpression(exp, needCheck, nil)
+			if err != nil {
+				t.Fatalf("NewExpression(%q) error = %v", exp, err)
+			}
+			for ln, lv := range valueKinds {
+				for rn, rv := range valueKinds {
+					func() {
+						defer func() {
+							if r := recover(); r != nil {
+								t.Errorf("%q NeedCheck=%v a=%s b=%s panic: %v", exp, needCheck, ln, rn, r)
+							}
+						}()
+						_, err := e.Execute(map[string]any{"a": lv, "b": rv})
+						var (
+							te *TypeError
+							ee *EvalError
+						)
+						if err != nil && !errors.As(err, &te) && !errors.As(err, &ee) {
+							t.Errorf("%q a=%s b=%s error = %v(%T), want *TypeError or *EvalError", exp, ln, rn, err, err)
+						}
+					}()
+				}
+			}
+		}
+	}
+}
+
+func TestExecute_InvalidOperation(t *testing.T) {
+	tests := []struct {
+		name         string
+		exp          string
+		params       map[string]any
+		wantOp       Operator
+		wantOperands []string
+	}{
+		{name: "and", exp: "a && true", params: map[string]any{"a": "yes"}, wantOp: AndAnd, wantOperands: []string{"string", "bool"}},
+		{name: "or", exp: "false || a", params: map[string]any{"a": int64(1)}, wantOp: OrOr, wantOperands: []string{"bool", "int"}},
+		{name: "not", exp: "!a", params: map[string]any{"a": 1.5}, wantOp: Not, wantOperands: []string{"number"}},
+		{name: "ternary", exp: "a ? 1 : 2", params: map[string]any{"a": []any{}}, wantOp: TernaryT, wantOperands: []string{"list"}},
+		{name: "compare", exp: "a < 1", params: map[string]any{"a": map[string]any{}}, wantOp: Lss, wantOperands: []string{"map[string]interface {}", "int"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExpression(tt.exp, false, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = e.Execute(tt.params)
+			var ee *EvalError
+			if !errors.As(err, &ee) {
+				t.Fatalf("Execute() error = %v, want *EvalError", err)
+			}
+			if ee.Op != tt.wantOp || !reflect.DeepEqual(ee.Operands, tt.wantOperands) {
+				t.Errorf("Execute() error op = %v %v, want %v %v", ee.Op, ee.Operands, tt.wantOp, tt.wantOperands)
+			}
+		})
+	}
+}
+
+func TestExecute_FunctionPanic(t *testing.T) {
+	e, err := NewExpression("a > 0 && boom(a, 'x')", true, map[string]Function{
+		"boom": func(args ...any) (any, error) { panic("index out of range") },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = e.Execute(map[string]any{"a": 1})
+	var ee *EvalError
+	if !errors.As(err, &ee) {
+		t.Fatalf("Execute() error = %v, want *EvalError", err)
+	}
+	want := "execute: line 1, column 10 (offset 9): panic: index out of range"
+	if err.Error() != want || !reflect.DeepEqual(ee.Operands, []string{"int", "string"}) {
+		t.Errorf("Execute() error = %v %v, want %s", err, ee.Operands, want)
+	}
+}
+
+// TestExecute_InstructionPanic 没有记录操作数的指令 panic 时只报告该指令的位置, 不使用之前指令记录的栈
+func TestExecute_InstructionPanic(t *testing.T) {
+	tests := []struct {
+		name string
+		exp  string
+		op   opcode
+		want string
+	}{
+		{name: "load", exp: "-a + b", op: opLoad, want: "execute: line 1, column 6 (offset 5): panic: "},
+		{name: "store", exp: "c = -a", op: opStore, want: "execute: line 1, column 1 (offset 0): panic: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExpression(tt.exp, true, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 改写最后一条该类型指令的变量下标, 使其执行时越界 panic
+			for i := len(e.prog.code) - 1; i >= 0; i-- {
+				if e.prog.code[i].op == tt.op {
+					e.prog.code[i].arg = int32(len(e.prog.names))
+					break
+				}
+			}
+			_, err = e.Execute(map[string]any{"a": 1, "b": 2})
+			var ee *EvalError
+			if !errors.As(err, &ee) || !strings.HasPrefix(err.Error(), tt.want) || ee.Operands != nil {
+				t.Errorf("Execute() error = %v, want prefix %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzExecute(f *testing.F) {
+	for _, seed := range []string{"a + b * 2", "a ? b : 'x'", "!a || b && a", "a in [1, b]", "a.b[0] ** -1", "~a << b", "filter(a, x => x > b)", "reduce(a, (s, x) => s + x, 0)", "a?.b ?? c == null", "a * 1h30m - 2d / b", "0xFF & a + 1_000 * 1e3 - 0b1.2"} {
+		f.Add(seed, int64(1), "s", true)
+	}
+	f.Fuzz(func(t *testing.T, exp string, i int64, s string, b bool) {
+		e, err := NewExpression(exp, false, nil)
+		if err != nil {
+			return
+		}
+		for _, v := range []any{i, s, b, nil, []any{i, s}, map[string]any{"b": i}} {
+			_, _ = e.Execute(map[string]any{"a": v, "b": s})
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package goexpression
+
+// optimize 编译期优化, 自底向上改写抽象语法树:
+//   - 常量折叠: 操作数均为字面量的操作符直接计算为字面量, 如 60 * 60 * 24、'prefix_' + 'x'
+//   - 逻辑化简: false && a => false, true || a => true, 右侧结果必为 bool 时 true && a => a, false || a => a
+//     左侧结果必为 bool 时 a && true => a, a || false => a; a 仍然执行, 其中缺少的变量、类型错误等照常报告
+//   - in 的右侧为字面量集合时预先构造 []any
+//   - 三元表达式条件为字面量时裁剪不会执行的分支, ?? 左侧为字面量时直接选择结果
+//   - 成员访问的对象与 key 均为字面量时直接取值
+//   - 丢弃 ';' 前结果为字面量的语句
+//
+// 计算出错或类型检查不通过的子树保持原样, 错误留到执行时按原有方式报告
+func optimize(node *astNode, decimal *decimalContext) *astNode {
+	return optimizeNode(node, false, decimal)
+}
+
+// optimizeNode partial 为 true 时用于 PartialEval, 另外在左侧结果必为 bool 且没有副作用(函数调用、赋值、++、--)时
+// a && false => false, a || true => true, 结果由已知的右侧确定, a 不再执行, 其中的执行错误也不再报告
+// decimal 不为 nil 时常量折叠与执行时一样按精确小数模式计算
+func optimizeNode(node *astNode, partial bool, decimal *decimalContext) *astNode {
+	if node == nil {
+		return nil
+	}
+	node.left, node.right = optimizeNode(node.left, partial, decimal), optimizeNode(node.right, partial, decimal)
+	switch node.kind {
+	case indexNode:
+		return foldIndex(node)
+	case seqNode:
+		if node.left.kind == litNode {
+			return node.right
+		}
+	case ternaryNode:
+		then, els := ternaryBranches(node)
+		if isLit(node.left, true) {
+			return then
+		}
+		if isLit(node.left, false) {
+			return els
+		}
+	}
+	if node.kind != opNode {
+		return node
+	}
+
+	switch node.op {
+	case AndAnd:
+		if isLit(node.left, false) {
+			return node.left
+		}
+		if isLit(node.left, true) && isBoolNode(node.right) {
+			return node.right
+		}
+		if isLit(node.right, true) && isBoolNode(node.left) {
+			return node.left
+		}
+		if partial && isLit(node.right, false) && isBoolNode(node.left) && isPure(node.left) {
+			return &astNode{kind: litNode, value: false, span: node.span}
+		}
+	case OrOr:
+		if isLit(node.left, true) {
+			return node.left
+		}
+		if isLit(node.left, false) && isBoolNode(node.right) {
+			return node.right
+		}
+		if isLit(node.right, false) && isBoolNode(node.left) {
+			return node.left
+		}
+		if partial && isLit(node.right, true) && isBoolNode(node.left) && isPure(node.left) {
+			return &astNode{kind: litNode, value: true, span: node.span}
+		}
+	case Coalesce:
+		if node.left != nil && node.left.kind == litNode {
+			if node.left.value != nil {
+				return node.left
+			}
+			return node.right
+		}
+	case In:
+		if list, ok := litList(node.right); ok {
+			node.right = &astNode{kind: litNode, value: list, span: node.right.span}
+		}
+	}
+	return fold(node, decimal)
+}
+
+// fold 操作数均为字面量时计算结果
+func fold(node *astNode, decimal *decimalContext) *astNode {
+	var left, right any
+	if node.left == nil || node.left.kind != litNode {
+		return node
+	}
+	left = node.left.value
+	if node.op.IsBinaryOperator() {
+		if node.right != nil && node.right.kind != litNode {
+			return node
+		}
+		if node.right != nil {
+			right = node.right.value
+		}
+	}
+	// 无论是否开启 NeedCheck 都检查类型, 避免编译期计算时类型断言失败
+	if check := typeCheckArray[node.op]; check != nil && !check(left, right) {
+		return node
+	}
+	var (
+		ret any
+		err error
+	)
+	if decimal != nil {
+		ret, err = decimal.operate(node.op, left, right, nil)
+	} else {
+		ret, err = opFuncArray[node.op](left, right, nil)
+	}
+	if err != nil {
+		return node
+	}
+	return &astNode{kind: litNode, value: ret, span: node.span}
+}
+
+// foldIndex 对象与 key 均为字面量时取值, 取值出错时保持原样
+func foldIndex(node *astNode) *astNode {
+	if node.left == nil || node.right == nil || node.left.kind != litNode || node.right.kind != litNode {
+		return node
+	}
+	ret, err := lookup(node.left.value, node.right.value, node.value == true)
+	if err != nil {
+		return node
+	}
+	return &astNode{kind: litNode, value: slotOf(ret).value(), span: node.span}
+}
+
+// litList 元素均为字面量的集合, 按 commaFunc 的连接方式构造 []any
+func litList(node *astNode) ([]any, bool) {
+	if node == nil || node.kind != listNode {
+		return nil, false
+	}
+	items := commaItems(node.left)
+	slots := make([]slot, len(items))
+	for i, item := range items {
+		if item != nil && item.kind != litNode {
+			return nil, false
+		}
+		if item != nil {
+			slots[i] = slotOf(item.value)
+		}
+	}
+	return makeList(slots), true
+}
+
+func isLit(node *astNode, value any) bool {
+	return node != nil && node.kind == litNode && node.value == value
+}
+
+// isPure 子树不含函数调用、赋值与 ++、--, 省略执行不影响结果以外的状态
+func isPure(node *astNode) bool {
+	if node == nil {
+		return true
+	}
+	switch {
+	case node.kind == funcNode, node.kind == assignNode:
+		return false
+	case node.kind == opNode && (node.op == AddAdd || node.op == SubSub):
+		return false
+	}
+	return isPure(node.left) && isPure(node.right)
+}
+
+// isBoolNode 节点执行结果是否一定为 bool
+func isBoolNode(node *astNode) bool {
+	if node == nil {
+		return false
+	}
+	switch node.kind {
+	case litNode:
+		return IsBool(node.value)
+	case opNode:
+		switch node.op {
+		case OrOr, AndAnd, Eql, Neq, Lss, Leq, Gtr, Geq, In, Match, NotMatch, Not:
+			return true
+		}
+	case builtinNode:
+		switch node.value.(builtin) {
+		case builtinAll, builtinAny, builtinNone:
+			return true
+		}
+	}
+	return false
+}
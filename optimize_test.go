@@ -0,0 +1,93 @@
+package goexpression
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	params := map[string]any{"a": true, "x": 2.0, "s": "x"}
+	tests := []struct {
+		name    string
+		exp     string
+		wantLit bool // 优化后是否为字面量
+		want    any
+	}{
+		{name: "arith", exp: "60 * 60 * 24", wantLit: true, want: int64(86400)},
+		{name: "minus", exp: "-(2 ** 3) + ~0", wantLit: true, want: int64(-9)},
+		{name: "string", exp: "'prefix_' + 'x'", wantLit: true, want: "prefix_x"},
+		{name: "compare", exp: "1 + 2 == 3 && 'a' < 'b'", wantLit: true, want: true},
+		{name: "and false", exp: "false && a", wantLit: true, want: false},
+		{name: "or true", exp: "true || a", wantLit: true, want: true},
+		{name: "and false right", exp: "x > 1 && false", wantLit: false, want: false},
+		{name: "or true right", exp: "s == 'y' || true", wantLit: false, want: true},
+		{name: "and true right", exp: "x > 1 && true", wantLit: false, want: true},
+		{name: "assign kept", exp: "(x = 3) > 1 && false; x", wantLit: false, want: int64(3)},
+		{name: "and true bool", exp: "true && x > 1", wantLit: false, want: true},
+		{name: "and true var", exp: "true && a", wantLit: false, want: true},
+		{name: "ternary true", exp: "1 < 2 ? x * 2 : s", wantLit: false, want: 4.0},
+		{name: "ternary false", exp: "1 > 2 ? x : 'no'", wantLit: true, want: "no"},
+		{name: "ternary nested", exp: "true ? false ? 1 : 2 : 3", wantLit: true, want: int64(2)},
+		{name: "in literal list", exp: "x in [1, 2, 3]", wantLit: false, want: true},
+		{name: "in constant", exp: "2 in [1, 1 + 1, []]", wantLit: true, want: true},
+		{name: "partial", exp: "1 + 2 + x", wantLit: false, want: 5.0},
+		{name: "type error kept", exp: "x > 1 || 1 + true", wantLit: false, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExpression(tt.exp, true, nil)
+			if err != nil {
+				t.Fatalf("NewExpression() error = %v", err)
+			}
+			if isLit := e.root.kind == litNode; isLit != tt.wantLit {
+				t.Errorf("root is literal = %v, want %v", isLit, tt.wantLit)
+			}
+			got, err := e.Execute(params)
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Execute() got = %v, want %v", got, tt.want)
+			}
+
+			raw, err := NewExpression(tt.exp, true, nil, WithoutOptimization())
+			if err != nil {
+				t.Fatalf("NewExpression() error = %v", err)
+			}
+			if rawGot, err := raw.Execute(params); err != nil || !reflect.DeepEqual(rawGot, got) {
+				t.Errorf("WithoutOptimization Execute() got = %v, %v, want %v", rawGot, err, got)
+			}
+		})
+	}
+}
+
+// TestOptimize_KeepsErrors 右侧为常量的 && 与 || 不折叠, 左侧的执行错误照常报告
+func TestOptimize_KeepsErrors(t *testing.T) {
+	params := map[string]any{"x": 2.0}
+	tests := []struct {
+		name    string
+		exp     string
+		wantErr string
+	}{
+		{name: "missing and false", exp: "missing > 1 && false", wantErr: "missing param not in the passed parameter list"},
+		{name: "missing or true", exp: "missing == 1 || true", wantErr: "missing param not in the passed parameter list"},
+		{name: "type mismatch and false", exp: "x > 'a' && false", wantErr: "invalid operation"},
+		{name: "type mismatch or true", exp: "x > 'a' || true", wantErr: "invalid operation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkExecute(t, tt.exp, nil, tt.wantErr, params, nil)
+		})
+	}
+}
+
+func TestOptimize_InList(t *testing.T) {
+	e, err := NewExpression("x in [1, 2, 'a', []]", true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	right := e.root.right
+	if right == nil || right.kind != litNode || !reflect.DeepEqual(right.value, []any{int64(1), int64(2), "a", nil}) {
+		t.Errorf("in right = %+v, want literal list", right)
+	}
+}
@@ -0,0 +1,123 @@
+package goexpression
+
+import "time"
+
+// Option NewExpression 的可选配置
+type Option func(*config)
+
+// config 表达式编译配置
+type config struct {
+	disableOptimization bool
+	schema              *Schema
+	allErrors           bool
+	contextFunctions    map[string]ContextFunction
+	limits              Limits
+	writeBack           bool
+	stdlib              bool
+	lenient             bool
+	clock               func() time.Time
+	decimal             *decimalContext
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// WithoutOptimization 关闭编译期优化(常量折叠、死分支裁剪等), 调试时可以保持与原始表达式一致的语法树
+func WithoutOptimization() Option {
+	return func(c *config) {
+		c.disableOptimization = true
+	}
+}
+
+// WithSchema 声明变量与函数的类型, 编译时进行静态类型检查, 类型错误的表达式将被拒绝
+func WithSchema(schema Schema) Option {
+	return func(c *config) {
+		c.schema = &schema
+	}
+}
+
+// WithAllErrors 遇到语法错误时继续解析, 返回包含全部语法错误的 ErrorList, 而不是只返回第一个错误
+func WithAllErrors() Option {
+	return func(c *config) {
+		c.allErrors = true
+	}
+}
+
+// WithContextFunctions 注册可感知 context 的函数, 与 NewExpression 传入的同名函数冲突时以此处注册的为准
+func WithContextFunctions(functions map[string]ContextFunction) Option {
+	return func(c *config) {
+		c.contextFunctions = functions
+	}
+}
+
+// Limits 资源限制, 用于编译、执行不完全可信的表达式, 各项为 0 时不限制
+// 超出限制时返回 *LimitError
+type Limits struct {
+	MaxLength    int // 表达式源码的最大字节数
+	MaxDepth     int // 最大嵌套深度, 包括括号嵌套与语法树深度, 避免过深的表达式耗尽栈
+	MaxSteps     int // 单次执行最多执行的指令数
+	MaxStringLen int // 运算、函数返回的字符串的最大字节数
+	MaxListLen   int // 集合、函数返回的 []any 的最大长度
+	MaxCalls     int // 单次执行最多调用函数的次数
+}
+
+// WithLimits 设置资源限制
+func WithLimits(limits Limits) Option {
+	return func(c *config) {
+		c.limits = limits
+	}
+}
+
+// WithWriteBack 执行时将赋值与变量的 ++、-- 结果写回传入的 params, 默认只在本次执行内可见
+// 写回会修改调用方的 map, 并发执行时不能共享同一个 params
+// 已有的数值参数写回时保持原有类型, 如 int 变量 ++ 后仍为 int; 结果无法用原类型表示时(如 int 变量赋值为 1.5、超出范围)
+// 写回执行时的类型 int64、float64 或 Decimal, 新增的变量同样为执行时的类型
+func WithWriteBack() Option {
+	return func(c *config) {
+		c.writeBack = true
+	}
+}
+
+// WithStdlib 注册标准库函数: 数学 abs、min、max、floor、ceil、round、sqrt、log,
+// 字符串 len、lower、upper、trim、contains、startsWith、endsWith、split、join、replace、substr,
+// 类型转换 int、float、string、bool, 时间 now、date、duration、year、month、day、hour、minute、weekday、format;
+// NewExpression 或 WithContextFunctions 传入的同名函数优先
+func WithStdlib() Option {
+	return func(c *config) {
+		c.stdlib = true
+	}
+}
+
+// WithLenientLookup 宽松查找: 执行时 params 中不存在的变量为 nil, 而不是返回错误
+// 成员不存在时仍返回错误, 可用 a?.b 或 a.b ?? 默认值处理可选的成员
+func WithLenientLookup() Option {
+	return func(c *config) {
+		c.lenient = true
+	}
+}
+
+// WithClock 替换标准库函数 now() 使用的时钟, 默认为 time.Now, 便于测试与回放; 需要同时启用 WithStdlib
+func WithClock(clock func() time.Time) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// WithDecimal 精确小数模式: 含小数点的数值字面量解析为 Decimal, 执行时读取的 float64 变量、成员与函数返回值按最短十进制表示转换为 Decimal,
+// 加减乘、取余与比较的结果是精确的, 如 0.1 + 0.2 == 0.3; 除法(包括整数相除)的结果为 Decimal, 保留 scale 位小数并按 rounding 舍入
+// 整数的其他运算结果仍为 int64, 溢出时为精确的 Decimal 而不是回绕
+func WithDecimal(scale int, rounding Rounding) Option {
+	return func(c *config) {
+		if scale < 0 {
+			scale = 0
+		} else if scale > maxDecimalScale {
+			scale = maxDecimalScale
+		}
+		c.decimal = &decimalContext{scale: int32(scale), rounding: rounding}
+	}
+}
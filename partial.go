@@ -0,0 +1,94 @@
+package goexpression
+
+import "fmt"
+
+// PartialEval 代入已知的变量, 计算结果确定的部分并返回剩余的表达式, 适用于部分参数(如租户配置)先于其余参数已知的场景
+// 代入后按编译期优化的方式折叠常量、化简 && 与 ||、裁剪三元表达式的分支, 函数调用保持原样
+// 与编译期优化不同, && 与 || 已知的一侧在右边时同样确定结果, 如 a > 1 && false 为 false, 不再检查 a
+// 结果完全确定时 residual 为 nil, value 为表达式的值; 否则 residual 只需传入剩余的参数执行, 原表达式不受影响
+// 计算出错(如除零)的部分保持原样, 错误留到执行 residual 时报告
+func (e *Expression) PartialEval(known map[string]any) (residual *Expression, value any, err error) {
+	if e.root == nil || e.prog == nil {
+		return nil, nil, fmt.Errorf("execute: parse result is nil")
+	}
+	// 被赋值的变量在赋值前后的值不同, 不能直接代入, 改为在表达式开头赋值为已知的值
+	if e.prog.decimal != nil { // 与执行时读取变量一样转换为 Decimal
+		converted := make(map[string]any, len(known))
+		for name, v := range known {
+			converted[name] = e.prog.slotOf(v).value()
+		}
+		known = converted
+	}
+	assigned := assignedVars(e.root)
+	root := substitute(e.root, known, assigned)
+	for _, name := range sortedKeys(assigned) {
+		if v, ok := known[name]; ok {
+			value := &astNode{kind: litNode, value: slotOf(v).value(), span: root.span}
+			init := &astNode{kind: assignNode, left: &astNode{kind: varNode, value: name, span: root.span}, right: value, span: root.span}
+			root = &astNode{kind: seqNode, left: init, right: root, span: root.span}
+		}
+	}
+	root = optimizeNode(root, true, e.prog.decimal)
+	if root.kind == litNode {
+		return nil, root.value, nil
+	}
+	residual = &Expression{root: root, NeedCheck: e.NeedCheck}
+	if residual.prog, err = compile(root, e.prog.src, &config{limits: e.prog.limits, writeBack: e.prog.writeBack, lenient: e.prog.lenient, decimal: e.prog.decimal}); err != nil {
+		return nil, nil, err
+	}
+	return residual, nil, nil
+}
+
+// substitute 复制语法树并将已知变量替换为字面量, optimize 会原地改写语法树, 因此不能共享原表达式的节点
+// 被赋值的变量 assigned 与 lambda 的参数不替换
+func substitute(node *astNode, known map[string]any, assigned map[string]struct{}) *astNode {
+	if node == nil {
+		return nil
+	}
+	if node.kind == lambdaNode {
+		skip := make(map[string]struct{}, len(assigned))
+		for name := range assigned {
+			skip[name] = struct{}{}
+		}
+		for _, name := range node.value.([]string) {
+			skip[name] = struct{}{}
+		}
+		assigned = skip
+	}
+	if node.kind == varNode {
+		name := node.value.(string)
+		if v, ok := known[name]; ok && !isAssigned(assigned, name) {
+			// 与执行时加载参数一样统一数值类型
+			return &astNode{kind: litNode, value: slotOf(v).value(), span: node.span}
+		}
+	}
+	ret := *node
+	ret.left, ret.right = substitute(node.left, known, assigned), substitute(node.right, known, assigned)
+	return &ret
+}
+
+func isAssigned(assigned map[string]struct{}, name string) bool {
+	_, ok := assigned[name]
+	return ok
+}
+
+// assignedVars 表达式中被赋值或 ++、-- 的变量
+func assignedVars(root *astNode) map[string]struct{} {
+	ret := map[string]struct{}{}
+	var visit func(node *astNode)
+	visit = func(node *astNode) {
+		if node == nil {
+			return
+		}
+		switch {
+		case node.kind == assignNode:
+			ret[node.left.value.(string)] = struct{}{}
+		case node.kind == opNode && (node.op == AddAdd || node.op == SubSub) && node.left != nil && node.left.kind == varNode:
+			ret[node.left.value.(string)] = struct{}{}
+		}
+		visit(node.left)
+		visit(node.right)
+	}
+	visit(root)
+	return ret
+}
@@ -0,0 +1,111 @@
+package goexpression
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpression_PartialEval(t *testing.T) {
+	functions := map[string]Function{
+		"double": func(params ...any) (any, error) { return params[0].(int64) * 2, nil },
+	}
+	tests := []struct {
+		name      string
+		exp       string
+		known     map[string]any
+		wantValue any      // 结果完全确定时的值
+		wantVars  []string // 剩余表达式引用的变量
+		params    map[string]any
+		want      any // 剩余表达式传入 params 执行的结果
+	}{
+		{name: "fully determined", exp: "tier == 'gold' && limit * 2 > 100", known: map[string]any{"tier": "gold", "limit": int32(60)}, wantValue: true},
+		{name: "short-circuit &&", exp: "tier == 'gold' && amount > 100", known: map[string]any{"tier": "silver"}, wantValue: false},
+		{name: "constant on the right &&", exp: "amount > 100 && tier == 'gold'", known: map[string]any{"tier": "silver"}, wantValue: false},
+		{name: "constant on the right ||", exp: "amount > 100 || vip", known: map[string]any{"vip": true}, wantValue: true},
+		{
+			name: "right true kept left", exp: "amount > 100 && tier == 'gold'", known: map[string]any{"tier": "gold"},
+			wantVars: []string{"amount"}, params: map[string]any{"amount": 101}, want: true,
+		},
+		{
+			name: "calls are not dropped", exp: "double(amount) > 100 && vip", known: map[string]any{"vip": false},
+			wantVars: []string{"amount"}, params: map[string]any{"amount": 1}, want: false,
+		},
+		{name: "short-circuit ||", exp: "vip || amount > 100", known: map[string]any{"vip": true}, wantValue: true},
+		{name: "ternary", exp: "(vip ? rate : 0.5) * amount", known: map[string]any{"vip": false}, wantVars: []string{"amount"}, params: map[string]any{"amount": 10}, want: 5.0},
+		{
+			name: "residual", exp: "tier == 'gold' && amount > threshold * 10", known: map[string]any{"tier": "gold", "threshold": 5},
+			wantVars: []string{"amount"}, params: map[string]any{"amount": 51}, want: true,
+		},
+		{
+			name: "member access", exp: "cfg.limits.max > amount", known: map[string]any{"cfg": map[string]any{"limits": map[string]any{"max": 100}}},
+			wantVars: []string{"amount"}, params: map[string]any{"amount": 99}, want: true,
+		},
+		{
+			name: "functions are kept", exp: "double(base) > amount", known: map[string]any{"base": 3},
+			wantVars: []string{"amount"}, params: map[string]any{"amount": 5}, want: true,
+		},
+		{name: "nothing known", exp: "a + 1 > 2", wantVars: []string{"a"}, params: map[string]any{"a": 2}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExpression(tt.exp, true, functions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			residual, value, err := e.PartialEval(tt.known)
+			if err != nil {
+				t.Fatalf("PartialEval() error = %v", err)
+			}
+			if tt.wantVars == nil {
+				if residual != nil || value != tt.wantValue {
+					t.Fatalf("PartialEval() = %v, %v, want value %v", residual, value, tt.wantValue)
+				}
+				return
+			}
+			if residual == nil {
+				t.Fatalf("PartialEval() value = %v, want residual expression", value)
+			}
+			if got := residual.Variables(); !reflect.DeepEqual(got, tt.wantVars) {
+				t.Errorf("residual.Variables() = %v, want %v", got, tt.wantVars)
+			}
+			if got, err := residual.Execute(tt.params); err != nil || got != tt.want {
+				t.Errorf("residual.Execute() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpression_PartialEval_KeepsOriginal(t *testing.T) {
+	e, err := NewExpression("a > 1 && b > 1", true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := e.PartialEval(map[string]any{"a": 2}); err != nil {
+		t.Fatal(err)
+	}
+	// 原表达式不受影响, 仍需要全部参数
+	if got, err := e.Bool(map[string]any{"a": 0, "b": 2}); err != nil || got {
+		t.Errorf("Bool() = %v, %v, want false", got, err)
+	}
+	if got := e.Variables(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Variables() = %v, want [a b]", got)
+	}
+}
+
+func TestExpression_PartialEval_DeferredError(t *testing.T) {
+	e, err := NewExpression("b > 0 || a / zero > 1", true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	residual, _, err := e.PartialEval(map[string]any{"a": 1, "zero": 0})
+	if err != nil || residual == nil {
+		t.Fatalf("PartialEval() = %v, %v, want residual", residual, err)
+	}
+	// 除零的部分保持原样, 只有执行到时才报错
+	if got, err := residual.Bool(map[string]any{"b": 1}); err != nil || !got {
+		t.Errorf("Bool() = %v, %v, want true", got, err)
+	}
+	if _, err := residual.Bool(map[string]any{"b": 0}); err == nil {
+		t.Error("Bool() error = nil, want division by zero")
+	}
+}
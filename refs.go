@@ -0,0 +1,141 @@
+package goexpression
+
+import (
+	"sort"
+	"strings"
+)
+
+// Variables 返回表达式引用的变量, 按字典序排列, 可用于只获取表达式需要的参数
+// 成员访问返回完整路径, 如 user.profile['age'] 返回 user.profile.age, 路径的第一段为参数名;
+// 路径在第一个非字符串常量的 key 处截断, 如 order.items[0].price 返回 order.items, tags[i] 返回 tags 与 i
+// 编译期优化裁剪掉的部分(如 false && a 中的 a)不会被执行, 因此不包含在内
+// 赋值之后读取的变量(如 total = a * b; total > 1 中的 total)不是参数, 不包含在内; &&、||、三元分支中的赋值可能不执行, 不影响之后的读取
+// lambda 的参数(如 filter(items, x => x > 1) 中的 x)不是参数, 不包含在内
+func (e *Expression) Variables() []string {
+	vars, _, _ := e.refs()
+	return vars
+}
+
+// Functions 返回表达式调用的函数名, 包括 filter 等内置集合函数, 按字典序排列
+func (e *Expression) Functions() []string {
+	_, funcs, builtins := e.refs()
+	// 注册了同名函数时不会解析为内置函数, 因此两者没有重复
+	funcs = append(funcs, builtins...)
+	sort.Strings(funcs)
+	return funcs
+}
+
+// IsConstant 表达式是否为常量, 即不引用变量也不调用注册的函数, 每次执行的结果都相同
+func (e *Expression) IsConstant() bool {
+	vars, funcs, _ := e.refs()
+	return e.root != nil && len(vars) == 0 && len(funcs) == 0
+}
+
+// refs 表达式引用的变量、注册的函数与内置函数
+func (e *Expression) refs() (vars, funcs, builtins []string) {
+	var (
+		varSet     = map[string]struct{}{}
+		funcSet    = map[string]struct{}{}
+		builtinSet = map[string]struct{}{}
+		assigned   = map[string]bool{} // 一定已赋值的变量或 lambda 参数
+		branch     int                 // 当前所在的可能不执行的分支层数
+		visit      func(node *astNode)
+	)
+	// 按执行顺序遍历, 以区分赋值前后的读取
+	visit = func(node *astNode) {
+		if node == nil {
+			return
+		}
+		switch node.kind {
+		case varNode, indexNode:
+			if path, ok := varPath(node); ok {
+				if !assigned[strings.SplitN(path, ".", 2)[0]] {
+					varSet[path] = struct{}{}
+				}
+				return
+			}
+		case funcNode:
+			funcSet[node.name] = struct{}{}
+		case builtinNode:
+			builtinSet[node.name] = struct{}{}
+		case lambdaNode:
+			// 函数体可能不执行, 其中的参数在函数体外恢复原有状态
+			params := node.value.([]string)
+			saved := make([]bool, len(params))
+			for i, name := range params {
+				saved[i], assigned[name] = assigned[name], true
+			}
+			branch++
+			visit(node.left)
+			branch--
+			for i, name := range params {
+				assigned[name] = saved[i]
+			}
+			return
+		case assignNode:
+			if node.op != NotOperator { // a += 1 先读取 a
+				visit(node.left)
+			}
+			visit(node.right)
+			if branch == 0 {
+				assigned[node.left.value.(string)] = true
+			}
+			return
+		case ternaryNode:
+			then, els := ternaryBranches(node)
+			visit(node.left)
+			branch++
+			visit(then)
+			visit(els)
+			branch--
+			return
+		case opNode:
+			switch node.op {
+			case AndAnd, OrOr, Coalesce:
+				visit(node.left)
+				branch++
+				visit(node.right)
+				branch--
+				return
+			case AddAdd, SubSub:
+				visit(node.left)
+				if node.left != nil && node.left.kind == varNode && branch == 0 {
+					assigned[node.left.value.(string)] = true
+				}
+				return
+			}
+		}
+		visit(node.left)
+		visit(node.right)
+	}
+	visit(e.root)
+	return sortedKeys(varSet), sortedKeys(funcSet), sortedKeys(builtinSet)
+}
+
+// varPath 变量或以字符串常量为 key 的成员访问链对应的路径, 如 a['b'].c 为 a.b.c
+func varPath(node *astNode) (string, bool) {
+	switch node.kind {
+	case varNode:
+		return node.value.(string), true
+	case indexNode:
+		key, ok := node.right.value.(string)
+		if !ok || node.right.kind != litNode {
+			return "", false
+		}
+		path, ok := varPath(node.left)
+		if !ok {
+			return "", false
+		}
+		return path + "." + key, true
+	}
+	return "", false
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
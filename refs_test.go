@@ -0,0 +1,52 @@
+package goexpression
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpression_Refs(t *testing.T) {
+	functions := map[string]Function{"len": nil, "upper": nil}
+	tests := []struct {
+		name         string
+		exp          string
+		wantVars     []string
+		wantFuncs    []string
+		wantConstant bool
+	}{
+		{name: "vars and funcs", exp: "upper(name) == 'A' && age > 18 || upper(b) == name", wantVars: []string{"age", "b", "name"}, wantFuncs: []string{"upper"}},
+		{name: "member paths", exp: "user.profile['age'] > 18 && user.name == 'a'", wantVars: []string{"user.name", "user.profile.age"}, wantFuncs: []string{}},
+		{name: "path stops at non-string key", exp: "order.items[0].price > 1", wantVars: []string{"order.items"}, wantFuncs: []string{}},
+		{name: "dynamic index", exp: "tags[len(tags) - 1] == 'x'", wantVars: []string{"tags"}, wantFuncs: []string{"len"}},
+		{name: "pruned by optimization", exp: "false && a || b", wantVars: []string{"b"}, wantFuncs: []string{}},
+		{name: "constant", exp: "60 * 60 * 24 > 1000 && 'a' in ['a', 'b']", wantVars: []string{}, wantFuncs: []string{}, wantConstant: true},
+		{name: "function is not constant", exp: "len('abc') > 1", wantVars: []string{}, wantFuncs: []string{"len"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExpression(tt.exp, true, functions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := e.Variables(); !reflect.DeepEqual(got, tt.wantVars) {
+				t.Errorf("Variables() = %v, want %v", got, tt.wantVars)
+			}
+			if got := e.Functions(); !reflect.DeepEqual(got, tt.wantFuncs) {
+				t.Errorf("Functions() = %v, want %v", got, tt.wantFuncs)
+			}
+			if got := e.IsConstant(); got != tt.wantConstant {
+				t.Errorf("IsConstant() = %v, want %v", got, tt.wantConstant)
+			}
+		})
+	}
+}
+
+func TestExpression_IsConstant_WithoutOptimization(t *testing.T) {
+	e, err := NewExpression("1 + 2 > 2", true, nil, WithoutOptimization())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.IsConstant() {
+		t.Errorf("IsConstant() = false, want true")
+	}
+}
@@ -1,20 +1,25 @@
 package goexpression
 
-import "fmt"
+import (
+	"fmt"
+	"unicode/utf8"
+)
 
-// scanner 源码扫描器, 按顺序逐字符读取源码
+// scanner 源码扫描器, 按顺序逐字符(UTF-8 解码后的 rune)读取源码
 type scanner struct {
 	Raw   string
-	Index int
+	Index int // 下一个字符的字节偏移
+	Last  int // 最近一次读取的字符的字节偏移
 }
 
-// NextChar 读取一个字符
+// NextChar 读取一个字符, 非法的 UTF-8 编码返回 utf8.RuneError
 func (s *scanner) NextChar() (rune, bool) {
 	if s.Index >= len(s.Raw) {
 		return 0, false
 	}
-	r := rune(s.Raw[s.Index])
-	s.Index++
+	r, size := utf8.DecodeRuneInString(s.Raw[s.Index:])
+	s.Last = s.Index
+	s.Index += size
 	return r, true
 }
 
@@ -23,14 +28,37 @@ func (s *scanner) Peek() (rune, bool) {
 	if s.Index >= len(s.Raw) {
 		return 0, false
 	}
-	return rune(s.Raw[s.Index]), true
+	r, _ := utf8.DecodeRuneInString(s.Raw[s.Index:])
+	return r, true
 }
 
 // Rewind 倒带step个字符
 func (s *scanner) Rewind(step int) error {
-	if s.Index < step || s.Index-step > len(s.Raw) {
-		return fmt.Errorf("lexer: scanner Index: %d - step: %d < 0 || > len(s.Raw) %d", s.Index, step, len(s.Raw))
+	index := s.Index
+	for i := 0; i < step; i++ {
+		if index <= 0 {
+			return fmt.Errorf("lexer: scanner Index: %d rewind %d characters out of range", s.Index, step)
+		}
+		_, size := utf8.DecodeLastRuneInString(s.Raw[:index])
+		index -= size
 	}
-	s.Index -= step
+	s.Index = index
 	return nil
 }
+
+// Position 源码中的位置
+type Position struct {
+	Offset int // 字节偏移, 从 0 开始
+	Line   int // 行号, 从 1 开始
+	Column int // 列号, 按字符计数, 从 1 开始
+}
+
+// String 返回位置的可读形式
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d (offset %d)", p.Line, p.Column, p.Offset)
+}
+
+// Position 计算字节偏移 offset 对应的行列号
+func (s *scanner) Position(offset int) Position {
+	return position(s.Raw, offset)
+}
@@ -0,0 +1,339 @@
+package goexpression
+
+import (
+	"fmt"
+	"time"
+)
+
+// Type 静态类型, 用于编译期类型检查
+type Type int
+
+const (
+	TypeAny      Type = iota // 任意类型, 不做检查
+	TypeBool                 // bool
+	TypeNumber               // float64, 精确小数模式下为 Decimal
+	TypeString               // string
+	TypeList                 // []any
+	TypeInt                  // int64, 可以用在需要 TypeNumber 的地方
+	TypeTime                 // time.Time
+	TypeDuration             // time.Duration
+)
+
+var typeNames = [...]string{"any", "bool", "number", "string", "list", "int", "time", "duration"}
+
+// String 类型名称
+func (t Type) String() string {
+	if t < 0 || int(t) >= len(typeNames) {
+		return fmt.Sprintf("Type(%d)", int(t))
+	}
+	return typeNames[t]
+}
+
+// typeExemplars 各类型的代表值, 类型推导时以代表值执行 typeCheckArray 与 opFuncArray
+// 从而复用运行时的类型规则, 数值取 1 避免除零
+var typeExemplars = [...]any{nil, true, 1.0, "", []any{}, int64(1), time.Time{}, time.Duration(1)}
+
+// typeOf 值对应的静态类型
+func typeOf(v any) Type {
+	switch v.(type) {
+	case bool:
+		return TypeBool
+	case float64, Decimal:
+		return TypeNumber
+	case int64:
+		return TypeInt
+	case string:
+		return TypeString
+	case []any:
+		return TypeList
+	case time.Time:
+		return TypeTime
+	case time.Duration:
+		return TypeDuration
+	default:
+		return TypeAny
+	}
+}
+
+// Signature 函数签名
+type Signature struct {
+	Params   []Type // 参数类型
+	Variadic bool   // 最后一个参数是否可重复任意次
+	Result   Type   // 返回值类型
+}
+
+// Schema 变量与函数的类型声明
+// 表达式中使用未在 Vars 中声明的变量时编译失败, 未在 Funcs 中声明签名的函数不检查参数, 返回值视为 TypeAny
+type Schema struct {
+	Vars  map[string]Type
+	Funcs map[string]Signature
+}
+
+// infer 推导节点的结果类型, 同时检查操作数类型
+func (s *Schema) infer(node *astNode) (Type, error) {
+	if node == nil {
+		return TypeAny, nil
+	}
+	switch node.kind {
+	case litNode:
+		return typeOf(node.value), nil
+	case varNode:
+		t, ok := s.Vars[node.value.(string)]
+		if !ok {
+			return TypeAny, typeErrorf(node, "variable %s is not declared in schema", node.value)
+		}
+		return t, nil
+	case funcNode:
+		return s.inferFunc(node)
+	case builtinNode:
+		return s.inferBuiltin(node)
+	case ternaryNode:
+		return s.inferTernary(node)
+	case listNode:
+		if _, err := s.inferList(node.left); err != nil {
+			return TypeAny, err
+		}
+		return TypeList, nil
+	case indexNode:
+		// 成员与元素类型未声明, 只检查对象可以被索引
+		objType, err := s.infer(node.left)
+		if err != nil {
+			return TypeAny, err
+		}
+		if _, err = s.infer(node.right); err != nil {
+			return TypeAny, err
+		}
+		if objType != TypeAny && objType != TypeList {
+			err := typeErrorf(node, "cannot index %s", objType)
+			err.Operands = []string{objType.String()}
+			return TypeAny, err
+		}
+		return TypeAny, nil
+	case assignNode:
+		return s.inferAssign(node)
+	case seqNode:
+		if _, err := s.infer(node.left); err != nil {
+			return TypeAny, err
+		}
+		return s.infer(node.right)
+	default:
+		return s.inferOp(node)
+	}
+}
+
+// inferAssign 推导赋值的类型, 已声明的变量只能赋值为可赋值给其声明类型的值, 未声明的变量按首次赋值的类型(int 按 number)记录在 Vars 中
+func (s *Schema) inferAssign(node *astNode) (Type, error) {
+	value := node.right
+	if node.op != NotOperator {
+		value = &astNode{kind: opNode, op: node.op, left: node.left, right: node.right, span: node.span}
+	}
+	t, err := s.infer(value)
+	if err != nil {
+		return TypeAny, err
+	}
+	name := node.left.value.(string)
+	declared, ok := s.Vars[name]
+	if !ok {
+		if t == TypeInt { // 之后可能被赋值为小数, 如 n = 1; n += 0.5
+			s.Vars[name] = TypeNumber
+		} else {
+			s.Vars[name] = t
+		}
+		return t, nil
+	}
+	if !assignable(t, declared) {
+		err := typeErrorf(node, "cannot assign %s to %s (%s)", t, name, declared)
+		err.Operands = []string{t.String()}
+		return TypeAny, err
+	}
+	return t, nil
+}
+
+// inferList 推导 ',' 连接的各元素类型
+func (s *Schema) inferList(node *astNode) ([]Type, error) {
+	items := commaItems(node)
+	ret := make([]Type, len(items))
+	for i, item := range items {
+		t, err := s.infer(item)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = t
+	}
+	return ret, nil
+}
+
+func (s *Schema) inferFunc(node *astNode) (Type, error) {
+	var args []Type
+	if node.right != nil {
+		var err error
+		if args, err = s.inferList(node.right); err != nil {
+			return TypeAny, err
+		}
+	}
+	sig, ok := s.Funcs[node.name]
+	if !ok {
+		return TypeAny, nil
+	}
+	minArgs := len(sig.Params)
+	if sig.Variadic {
+		minArgs--
+	}
+	if len(args) < minArgs || len(args) > len(sig.Params) && !sig.Variadic {
+		return TypeAny, typeErrorf(node, "%s expects %d arguments, got %d", node.name, len(sig.Params), len(args))
+	}
+	for i, arg := range args {
+		want := sig.Params[len(sig.Params)-1]
+		if i < len(sig.Params) {
+			want = sig.Params[i]
+		}
+		if !assignable(arg, want) {
+			err := typeErrorf(node, "%s argument %d expects %s, got %s", node.name, i+1, want, arg)
+			err.Operands = []string{arg.String()}
+			return TypeAny, err
+		}
+	}
+	return sig.Result, nil
+}
+
+// inferBuiltin 推导内置集合函数的类型, 集合参数须为 list, 条件 lambda 须返回 bool
+// lambda 的参数在函数体内视为 any, 函数体之外恢复同名变量原有的声明
+func (s *Schema) inferBuiltin(node *astNode) (Type, error) {
+	id := node.value.(builtin)
+	for i, arg := range builtinArgs(node) {
+		if arg.kind != lambdaNode {
+			t, err := s.infer(arg)
+			if err != nil {
+				return TypeAny, err
+			}
+			if i == 0 && !assignable(t, TypeList) {
+				err := typeErrorf(arg, "%v argument 1 expects list, got %s", id, t)
+				err.Operands = []string{t.String()}
+				return TypeAny, err
+			}
+			continue
+		}
+		params := arg.value.([]string)
+		shadowed := make(map[string]Type, len(params))
+		for _, name := range params {
+			if t, ok := s.Vars[name]; ok {
+				shadowed[name] = t
+			}
+			s.Vars[name] = TypeAny
+		}
+		t, err := s.infer(arg.left)
+		for _, name := range params {
+			delete(s.Vars, name)
+		}
+		for name, t := range shadowed {
+			s.Vars[name] = t
+		}
+		if err != nil {
+			return TypeAny, err
+		}
+		if id.predicate() && !assignable(t, TypeBool) {
+			err := typeErrorf(arg.left, "%v lambda must return bool, got %s", id, t)
+			err.Operands = []string{t.String()}
+			return TypeAny, err
+		}
+	}
+	switch id {
+	case builtinAll, builtinAny, builtinNone:
+		return TypeBool, nil
+	case builtinFilter, builtinMap, builtinSortBy:
+		return TypeList, nil
+	case builtinCount:
+		return TypeInt, nil
+	}
+	return TypeAny, nil
+}
+
+// inferTernary 推导三元表达式的类型, 条件须为 bool, 结果为两个分支之一
+// 分支中的赋值可能不执行, 但与 && 一样按执行时记录变量类型
+func (s *Schema) inferTernary(node *astNode) (Type, error) {
+	cond, err := s.infer(node.left)
+	if err != nil {
+		return TypeAny, err
+	}
+	if !assignable(cond, TypeBool) {
+		err := typeErrorf(node.left, "non-bool %s used as ? condition", cond)
+		err.Op, err.Operands = TernaryT, []string{cond.String()}
+		return TypeAny, err
+	}
+	then, els := ternaryBranches(node)
+	tt, err := s.infer(then)
+	if err != nil {
+		return TypeAny, err
+	}
+	et, err := s.infer(els)
+	if err != nil {
+		return TypeAny, err
+	}
+	return unify(tt, et), nil
+}
+
+// unify 结果为两者之一时的类型, 如三元表达式的两个分支、?? 的两侧
+func unify(a, b Type) Type {
+	switch {
+	case a == b:
+		return a
+	case assignable(a, TypeNumber) && assignable(b, TypeNumber):
+		return TypeNumber
+	}
+	return TypeAny
+}
+
+func (s *Schema) inferOp(node *astNode) (Type, error) {
+	var (
+		binary = node.op.IsBinaryOperator()
+		lt, rt Type
+		err    error
+	)
+	if lt, err = s.infer(node.left); err != nil {
+		return TypeAny, err
+	}
+	if binary {
+		if rt, err = s.infer(node.right); err != nil {
+			return TypeAny, err
+		}
+	}
+
+	switch node.op {
+	case In:
+		return TypeBool, nil
+	case Coalesce:
+		// 左侧为 nil 时结果为右侧
+		return unify(lt, rt), nil
+	}
+	if lt == TypeAny || binary && rt == TypeAny {
+		if isBoolNode(node) {
+			return TypeBool, nil
+		}
+		return TypeAny, nil
+	}
+
+	left, right := typeExemplars[lt], any(nil)
+	if binary {
+		right = typeExemplars[rt]
+	}
+	if check := typeCheckArray[node.op]; check != nil && !check(left, right) {
+		err := newOperandTypeError(node.op, binary, lt.String(), rt.String())
+		err.Span = node.span
+		return TypeAny, err
+	}
+	ret, err := opFuncArray[node.op](left, right, nil)
+	if err != nil {
+		return TypeAny, nil
+	}
+	return typeOf(ret), nil
+}
+
+// typeErrorf 节点的类型错误, 源码与行列号由 advanceTypeCheck 补充
+func typeErrorf(node *astNode, format string, args ...any) *TypeError {
+	return &TypeError{ErrorPos: ErrorPos{Span: node.span}, Msg: fmt.Sprintf(format, args...)}
+}
+
+// assignable 类型为 from 的值能否用在需要类型 to 的地方
+func assignable(from, to Type) bool {
+	return from == TypeAny || to == TypeAny || from == to || from == TypeInt && to == TypeNumber
+}
@@ -0,0 +1,58 @@
+package goexpression
+
+import "testing"
+
+func TestSchema(t *testing.T) {
+	schema := Schema{
+		Vars: map[string]Type{
+			"age":  TypeNumber,
+			"id":   TypeInt,
+			"name": TypeString,
+			"vip":  TypeBool,
+			"tags": TypeList,
+			"any":  TypeAny,
+		},
+		Funcs: map[string]Signature{
+			"upper": {Params: []Type{TypeString}, Result: TypeString},
+			"sum":   {Params: []Type{TypeNumber}, Variadic: true, Result: TypeNumber},
+		},
+	}
+	functions := map[string]Function{"upper": nil, "sum": nil, "raw": nil}
+	tests := []struct {
+		name    string
+		exp     string
+		wantErr bool
+	}{
+		{name: "ok", exp: "age > 18 && vip || name == 'root'"},
+		{name: "ok string concat", exp: "upper(name + '_x') == 'A_X'"},
+		{name: "ok variadic", exp: "sum(age, 1, 2) > 3 && sum() == 0"},
+		{name: "ok ternary", exp: "(vip ? age : 0) + 1 > 2"},
+		{name: "ok in", exp: "name in tags && age in [1, 2]"},
+		{name: "ok int promotes", exp: "sum(id, age) > 1 && id + 0.5 > age && (vip ? id : age) > 1"},
+		{name: "ok int bit", exp: "id & 1 == 0 && id << 2 > 1"},
+		{name: "ok any", exp: "any + 1 > 2 && raw(any) && !any"},
+		{name: "ok undeclared func", exp: "raw(age, name) == 1"},
+		{name: "number plus bool", exp: "1 + true", wantErr: true},
+		{name: "string minus", exp: "name - 'a' == ''", wantErr: true},
+		{name: "logic on number", exp: "age && vip", wantErr: true},
+		{name: "compare mismatch", exp: "age < name", wantErr: true},
+		{name: "not on string", exp: "!name", wantErr: true},
+		{name: "ternary cond", exp: "age ? 1 : 2", wantErr: true},
+		{name: "ternary branch", exp: "(vip ? age : name) + 1", wantErr: false},
+		{name: "ternary result", exp: "(vip ? age : 1) + name", wantErr: true},
+		{name: "int plus string", exp: "id + name", wantErr: true},
+		{name: "undeclared var", exp: "score > 1", wantErr: true},
+		{name: "func arg type", exp: "upper(age) == 'A'", wantErr: true},
+		{name: "func arity", exp: "upper(name, name) == 'A'", wantErr: true},
+		{name: "func result", exp: "upper(name) + 1", wantErr: true},
+		{name: "short circuit still checked", exp: "false && 1 + 'a' == 2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewExpression(tt.exp, true, functions, WithSchema(schema))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewExpression() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,593 @@
+package goexpression
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// stdlib 通过 WithStdlib 注册的标准库函数, NewExpression 传入的同名函数优先
+// 与内置集合函数一样, 函数名只在后跟 ( 时作为函数, 因此仍可以用作变量名
+// 数值参数在执行时已统一为 int64、float64 或 Decimal, 字符串的长度与下标按字符计算
+var stdlib = map[string]Function{
+	// 数学
+	"abs":   stdAbs,
+	"min":   func(params ...any) (any, error) { return stdExtreme("min", params, Lss) },
+	"max":   func(params ...any) (any, error) { return stdExtreme("max", params, Gtr) },
+	"floor": func(params ...any) (any, error) { return stdRound("floor", params, math.Floor, RoundFloor) },
+	"ceil":  func(params ...any) (any, error) { return stdRound("ceil", params, math.Ceil, RoundCeiling) },
+	"round": func(params ...any) (any, error) { return stdRound("round", params, math.Round, RoundHalfUp) },
+	"sqrt":  stdSqrt,
+	"log":   stdLog,
+	// 字符串
+	"len":        stdLen,
+	"lower":      func(params ...any) (any, error) { return stdStringFunc("lower", params, strings.ToLower) },
+	"upper":      func(params ...any) (any, error) { return stdStringFunc("upper", params, strings.ToUpper) },
+	"trim":       func(params ...any) (any, error) { return stdStringFunc("trim", params, strings.TrimSpace) },
+	"contains":   func(params ...any) (any, error) { return stdStringTest("contains", params, strings.Contains) },
+	"startsWith": func(params ...any) (any, error) { return stdStringTest("startsWith", params, strings.HasPrefix) },
+	"endsWith":   func(params ...any) (any, error) { return stdStringTest("endsWith", params, strings.HasSuffix) },
+	"split":      stdSplit,
+	"join":       stdJoin,
+	"replace":    stdReplace,
+	"substr":     stdSubstr,
+	// 类型转换
+	"int":    stdInt,
+	"float":  stdFloat,
+	"string": stdString,
+	"bool":   stdBool,
+	// 时间
+	"now":      stdNow(time.Now),
+	"date":     stdDate,
+	"duration": stdDuration,
+	"year":     func(params ...any) (any, error) { return stdTimeField("year", params, time.Time.Year) },
+	"month":    func(params ...any) (any, error) { return stdTimeField("month", params, monthOf) },
+	"day":      func(params ...any) (any, error) { return stdTimeField("day", params, time.Time.Day) },
+	"hour":     func(params ...any) (any, error) { return stdTimeField("hour", params, time.Time.Hour) },
+	"minute":   func(params ...any) (any, error) { return stdTimeField("minute", params, time.Time.Minute) },
+	"weekday":  func(params ...any) (any, error) { return stdTimeField("weekday", params, weekdayOf) },
+	"format":   stdFormat,
+}
+
+// stdlibSignatures 标准库函数的签名, 声明了 Schema 且未在 Schema.Funcs 中声明同名函数时用于类型检查
+var stdlibSignatures = map[string]Signature{
+	"abs":        {Params: []Type{TypeNumber}, Result: TypeNumber},
+	"min":        {Params: []Type{TypeAny}, Variadic: true, Result: TypeNumber},
+	"max":        {Params: []Type{TypeAny}, Variadic: true, Result: TypeNumber},
+	"floor":      {Params: []Type{TypeNumber}, Result: TypeNumber},
+	"ceil":       {Params: []Type{TypeNumber}, Result: TypeNumber},
+	"round":      {Params: []Type{TypeNumber}, Result: TypeNumber},
+	"sqrt":       {Params: []Type{TypeNumber}, Result: TypeNumber},
+	"log":        {Params: []Type{TypeNumber}, Result: TypeNumber},
+	"len":        {Params: []Type{TypeAny}, Result: TypeInt},
+	"lower":      {Params: []Type{TypeString}, Result: TypeString},
+	"upper":      {Params: []Type{TypeString}, Result: TypeString},
+	"trim":       {Params: []Type{TypeString}, Result: TypeString},
+	"contains":   {Params: []Type{TypeString, TypeString}, Result: TypeBool},
+	"startsWith": {Params: []Type{TypeString, TypeString}, Result: TypeBool},
+	"endsWith":   {Params: []Type{TypeString, TypeString}, Result: TypeBool},
+	"split":      {Params: []Type{TypeString, TypeString}, Result: TypeList},
+	"join":       {Params: []Type{TypeList, TypeString}, Result: TypeString},
+	"replace":    {Params: []Type{TypeString, TypeString, TypeString}, Result: TypeString},
+	"substr":     {Params: []Type{TypeString, TypeNumber, TypeNumber}, Variadic: true, Result: TypeString},
+	"int":        {Params: []Type{TypeAny}, Result: TypeInt},
+	"float":      {Params: []Type{TypeAny}, Result: TypeNumber},
+	"string":     {Params: []Type{TypeAny}, Result: TypeString},
+	"bool":       {Params: []Type{TypeAny}, Result: TypeBool},
+	"now":        {Result: TypeTime},
+	"date":       {Params: []Type{TypeString, TypeString}, Variadic: true, Result: TypeTime},
+	"duration":   {Params: []Type{TypeString}, Result: TypeDuration},
+	"year":       {Params: []Type{TypeTime}, Result: TypeInt},
+	"month":      {Params: []Type{TypeTime}, Result: TypeInt},
+	"day":        {Params: []Type{TypeTime}, Result: TypeInt},
+	"hour":       {Params: []Type{TypeTime}, Result: TypeInt},
+	"minute":     {Params: []Type{TypeTime}, Result: TypeInt},
+	"weekday":    {Params: []Type{TypeTime}, Result: TypeInt},
+	"format":     {Params: []Type{TypeTime, TypeString}, Variadic: true, Result: TypeString},
+}
+
+// stdArity 检查参数个数在 [min, max] 之间
+func stdArity(name string, params []any, min, max int) error {
+	if len(params) >= min && len(params) <= max {
+		return nil
+	}
+	want := strconv.Itoa(min)
+	if max > min {
+		want += " or " + strconv.Itoa(max)
+	}
+	return fmt.Errorf("execute: %s expects %s arguments, got %d", name, want, len(params))
+}
+
+func stdArgError(name string, i int, want string, got any) error {
+	return fmt.Errorf("execute: %s argument %d expects %s, got %s", name, i+1, want, typeName(got))
+}
+
+// stdNumberArg 第 i 个参数, 须为 int64、float64 或 Decimal
+func stdNumberArg(name string, params []any, i int) (any, error) {
+	if d, ok := params[i].(Decimal); ok {
+		return d, nil
+	}
+	if n, ok := asNumber(params[i]); ok {
+		return n, nil
+	}
+	return nil, stdArgError(name, i, "number", params[i])
+}
+
+func stdStringArg(name string, params []any, i int) (string, error) {
+	if s, ok := params[i].(string); ok {
+		return s, nil
+	}
+	return "", stdArgError(name, i, "string", params[i])
+}
+
+func stdTimeArg(name string, params []any, i int) (time.Time, error) {
+	if t, ok := params[i].(time.Time); ok {
+		return t, nil
+	}
+	return time.Time{}, stdArgError(name, i, "time", params[i])
+}
+
+// stdIntArg 第 i 个参数, 须为整数或没有小数部分的浮点数
+func stdIntArg(name string, params []any, i int) (int64, error) {
+	switch n := normalize(params[i]).(type) {
+	case int64:
+		return n, nil
+	case float64:
+		if n == math.Trunc(n) && math.Abs(n) < 1<<63 {
+			return int64(n), nil
+		}
+	case Decimal:
+		if i, ok := n.Int64(); ok && n.Cmp(NewDecimal(i, 0)) == 0 {
+			return i, nil
+		}
+	}
+	return 0, stdArgError(name, i, "integer", params[i])
+}
+
+func stdAbs(params ...any) (any, error) {
+	if err := stdArity("abs", params, 1, 1); err != nil {
+		return nil, err
+	}
+	n, err := stdNumberArg("abs", params, 0)
+	if err != nil {
+		return nil, err
+	}
+	switch n := n.(type) {
+	case int64:
+		if n == math.MinInt64 {
+			return nil, fmt.Errorf("execute: abs: %d out of int64 range", n)
+		}
+		if n < 0 {
+			return -n, nil
+		}
+		return n, nil
+	case Decimal:
+		if n.Sign() < 0 {
+			return n.neg(), nil
+		}
+		return n, nil
+	}
+	return math.Abs(n.(float64)), nil
+}
+
+// stdExtreme min 与 max, 参数为多个数值或一个集合, 整数之间比较时结果为 int64
+func stdExtreme(name string, params []any, op Operator) (any, error) {
+	if len(params) == 1 && params[0] != nil {
+		if list, ok := toList(params[0]); ok {
+			if len(list) == 0 {
+				return nil, fmt.Errorf("execute: %s of empty list", name)
+			}
+			params = list
+		}
+	}
+	if len(params) == 0 {
+		return nil, fmt.Errorf("execute: %s expects at least 1 argument, got 0", name)
+	}
+	ret, err := stdNumberArg(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(params); i++ {
+		n, err := stdNumberArg(name, params, i)
+		if err != nil {
+			return nil, err
+		}
+		better, err := opFuncArray[op](n, ret, nil)
+		if err != nil {
+			return nil, err
+		}
+		if better.(bool) {
+			ret = n
+		}
+	}
+	return ret, nil
+}
+
+// stdRound floor、ceil 与 round, 整数原样返回, Decimal 按 mode 舍入为整数
+func stdRound(name string, params []any, round func(float64) float64, mode Rounding) (any, error) {
+	if err := stdArity(name, params, 1, 1); err != nil {
+		return nil, err
+	}
+	n, err := stdNumberArg(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	switch n := n.(type) {
+	case float64:
+		return round(n), nil
+	case Decimal:
+		return n.Round(0, mode), nil
+	}
+	return n, nil
+}
+
+func stdSqrt(params ...any) (any, error) {
+	if err := stdArity("sqrt", params, 1, 1); err != nil {
+		return nil, err
+	}
+	n, err := stdNumberArg("sqrt", params, 0)
+	if err != nil {
+		return nil, err
+	}
+	f, _ := toFloat64(n)
+	if f < 0 {
+		return nil, fmt.Errorf("execute: sqrt of negative number %v", n)
+	}
+	return math.Sqrt(f), nil
+}
+
+// stdLog 自然对数
+func stdLog(params ...any) (any, error) {
+	if err := stdArity("log", params, 1, 1); err != nil {
+		return nil, err
+	}
+	n, err := stdNumberArg("log", params, 0)
+	if err != nil {
+		return nil, err
+	}
+	f, _ := toFloat64(n)
+	if f <= 0 {
+		return nil, fmt.Errorf("execute: log of non-positive number %v", n)
+	}
+	return math.Log(f), nil
+}
+
+// stdLen 字符串的字符数, 集合、切片、map 的长度
+func stdLen(params ...any) (any, error) {
+	if err := stdArity("len", params, 1, 1); err != nil {
+		return nil, err
+	}
+	switch v := params[0].(type) {
+	case nil:
+		return int64(0), nil
+	case string:
+		return int64(utf8.RuneCountInString(v)), nil
+	case []any:
+		return int64(len(v)), nil
+	}
+	switch rv := reflect.ValueOf(params[0]); rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return int64(rv.Len()), nil
+	}
+	return nil, stdArgError("len", 0, "string or list", params[0])
+}
+
+func stdStringFunc(name string, params []any, fn func(string) string) (any, error) {
+	if err := stdArity(name, params, 1, 1); err != nil {
+		return nil, err
+	}
+	s, err := stdStringArg(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	return fn(s), nil
+}
+
+func stdStringTest(name string, params []any, fn func(s, sub string) bool) (any, error) {
+	if err := stdArity(name, params, 2, 2); err != nil {
+		return nil, err
+	}
+	s, err := stdStringArg(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := stdStringArg(name, params, 1)
+	if err != nil {
+		return nil, err
+	}
+	return fn(s, sub), nil
+}
+
+func stdSplit(params ...any) (any, error) {
+	if err := stdArity("split", params, 2, 2); err != nil {
+		return nil, err
+	}
+	s, err := stdStringArg("split", params, 0)
+	if err != nil {
+		return nil, err
+	}
+	sep, err := stdStringArg("split", params, 1)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(s, sep)
+	ret := make([]any, len(parts))
+	for i, part := range parts {
+		ret[i] = part
+	}
+	return ret, nil
+}
+
+// stdJoin 连接集合的元素, 数值与 bool 元素按 string() 转换
+func stdJoin(params ...any) (any, error) {
+	if err := stdArity("join", params, 2, 2); err != nil {
+		return nil, err
+	}
+	list, ok := toList(params[0])
+	if !ok {
+		return nil, stdArgError("join", 0, "list", params[0])
+	}
+	sep, err := stdStringArg("join", params, 1)
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]string, len(list))
+	for i, item := range list {
+		s, err := stdString(item)
+		if err != nil {
+			return nil, fmt.Errorf("execute: join element %d: %w", i, err)
+		}
+		parts[i] = s.(string)
+	}
+	return strings.Join(parts, sep), nil
+}
+
+func stdReplace(params ...any) (any, error) {
+	if err := stdArity("replace", params, 3, 3); err != nil {
+		return nil, err
+	}
+	var args [3]string
+	for i := range args {
+		s, err := stdStringArg("replace", params, i)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = s
+	}
+	return strings.ReplaceAll(args[0], args[1], args[2]), nil
+}
+
+// stdSubstr substr(s, start[, length]) 按字符截取, length 超出末尾时截取到末尾
+func stdSubstr(params ...any) (any, error) {
+	if err := stdArity("substr", params, 2, 3); err != nil {
+		return nil, err
+	}
+	s, err := stdStringArg("substr", params, 0)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	start, err := stdIntArg("substr", params, 1)
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 || start > int64(len(runes)) {
+		return nil, fmt.Errorf("execute: substr start %d out of range [0, %d]", start, len(runes))
+	}
+	end := int64(len(runes))
+	if len(params) == 3 {
+		length, err := stdIntArg("substr", params, 2)
+		if err != nil {
+			return nil, err
+		}
+		if length < 0 {
+			return nil, fmt.Errorf("execute: substr length %d is negative", length)
+		}
+		if length < end-start {
+			end = start + length
+		}
+	}
+	return string(runes[start:end]), nil
+}
+
+// stdInt 转换为 int64: 浮点数截断, 字符串按十进制整数或小数解析, bool 为 1 或 0
+func stdInt(params ...any) (any, error) {
+	if err := stdArity("int", params, 1, 1); err != nil {
+		return nil, err
+	}
+	switch v := normalize(params[0]).(type) {
+	case int64:
+		return v, nil
+	case float64:
+		if math.IsNaN(v) || math.Abs(v) >= 1<<63 {
+			return nil, fmt.Errorf("execute: int: %v out of int64 range", v)
+		}
+		return int64(v), nil
+	case Decimal:
+		if i, ok := v.Int64(); ok {
+			return i, nil
+		}
+		return nil, fmt.Errorf("execute: int: %v out of int64 range", v)
+	case string:
+		s := strings.TrimSpace(v)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return stdInt(f)
+		}
+		return nil, fmt.Errorf("execute: int: cannot parse %q", v)
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	}
+	return nil, stdArgError("int", 0, "number, string or bool", params[0])
+}
+
+// stdFloat 转换为 float64: 字符串按十进制解析, bool 为 1 或 0
+func stdFloat(params ...any) (any, error) {
+	if err := stdArity("float", params, 1, 1); err != nil {
+		return nil, err
+	}
+	switch v := normalize(params[0]).(type) {
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case Decimal:
+		return v.Float64(), nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("execute: float: cannot parse %q", v)
+		}
+		return f, nil
+	case bool:
+		if v {
+			return 1.0, nil
+		}
+		return 0.0, nil
+	}
+	return nil, stdArgError("float", 0, "number, string or bool", params[0])
+}
+
+// stdString 转换为字符串, 浮点数使用不带指数的最短表示, 如 0.1、1000000
+func stdString(params ...any) (any, error) {
+	if err := stdArity("string", params, 1, 1); err != nil {
+		return nil, err
+	}
+	switch v := normalize(params[0]).(type) {
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case Decimal:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return nil, stdArgError("string", 0, "number, string or bool", params[0])
+}
+
+// stdBool 转换为 bool: 数值非 0 为 true, 字符串按 strconv.ParseBool 解析
+func stdBool(params ...any) (any, error) {
+	if err := stdArity("bool", params, 1, 1); err != nil {
+		return nil, err
+	}
+	switch v := normalize(params[0]).(type) {
+	case bool:
+		return v, nil
+	case int64:
+		return v != 0, nil
+	case float64:
+		return v != 0, nil
+	case Decimal:
+		return v.Sign() != 0, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("execute: bool: cannot parse %q", v)
+		}
+		return b, nil
+	}
+	return nil, stdArgError("bool", 0, "number, string or bool", params[0])
+}
+
+// stdNow now() 当前时间, 时钟可以通过 WithClock 替换
+func stdNow(clock func() time.Time) Function {
+	return func(params ...any) (any, error) {
+		if err := stdArity("now", params, 0, 0); err != nil {
+			return nil, err
+		}
+		return clock(), nil
+	}
+}
+
+// dateLayouts date 未指定格式时依次尝试的格式
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// stdDate date(s[, layout]) 解析时间, 未指定 layout 时支持 RFC3339、2006-01-02 15:04:05 与 2006-01-02
+// 字符串中没有时区时按 UTC 解析
+func stdDate(params ...any) (any, error) {
+	if err := stdArity("date", params, 1, 2); err != nil {
+		return nil, err
+	}
+	s, err := stdStringArg("date", params, 0)
+	if err != nil {
+		return nil, err
+	}
+	layouts := dateLayouts
+	if len(params) == 2 {
+		layout, err := stdStringArg("date", params, 1)
+		if err != nil {
+			return nil, err
+		}
+		layouts = []string{layout}
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("execute: date: cannot parse %q", s)
+}
+
+// stdDuration duration(s) 解析时长, 格式与时长字面量相同, 如 '1h30m'、'7d', 可以带负号
+func stdDuration(params ...any) (any, error) {
+	if err := stdArity("duration", params, 1, 1); err != nil {
+		return nil, err
+	}
+	s, err := stdStringArg("duration", params, 0)
+	if err != nil {
+		return nil, err
+	}
+	d, err := parseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("execute: duration: cannot parse %q", s)
+	}
+	return d, nil
+}
+
+// stdTimeField 取时间的年、月、日等字段, 结果为 int64
+func stdTimeField(name string, params []any, field func(time.Time) int) (any, error) {
+	if err := stdArity(name, params, 1, 1); err != nil {
+		return nil, err
+	}
+	t, err := stdTimeArg(name, params, 0)
+	if err != nil {
+		return nil, err
+	}
+	return int64(field(t)), nil
+}
+
+// monthOf 月份, 1 到 12
+func monthOf(t time.Time) int { return int(t.Month()) }
+
+// weekdayOf 星期, 0 为星期日
+func weekdayOf(t time.Time) int { return int(t.Weekday()) }
+
+// stdFormat format(t[, layout]) 按 Go 的时间格式格式化, 默认为 RFC3339
+func stdFormat(params ...any) (any, error) {
+	if err := stdArity("format", params, 1, 2); err != nil {
+		return nil, err
+	}
+	t, err := stdTimeArg("format", params, 0)
+	if err != nil {
+		return nil, err
+	}
+	layout := time.RFC3339
+	if len(params) == 2 {
+		if layout, err = stdStringArg("format", params, 1); err != nil {
+			return nil, err
+		}
+	}
+	return t.Format(layout), nil
+}
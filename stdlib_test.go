@@ -0,0 +1,143 @@
+package goexpression
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestWithStdlib(t *testing.T) {
+	params := map[string]any{
+		"name":  "  Alice Smith ",
+		"tags":  []string{"a", "b"},
+		"price": 12.5,
+		"qty":   int32(3),
+		"max":   7,
+		"mixed": []any{1, 2.5, true},
+		"empty": []any{},
+	}
+	tests := []struct {
+		name    string
+		exp     string
+		want    any
+		wantErr string
+	}{
+		{name: "abs", exp: "abs(-3) + abs(-1.5)", want: 4.5},
+		{name: "abs overflow", exp: "abs(-9223372036854775807 - 1)", wantErr: "abs: -9223372036854775808 out of int64 range"},
+		{name: "min max", exp: "min(3, 1.5, 2) + max(qty, 2)", want: 4.5},
+		{name: "min max int", exp: "max(1, 5, 3) - min([4, 2, 8])", want: int64(3)},
+		{name: "min of list", exp: "min(map([3, 4], x => x * price))", want: 37.5},
+		{name: "min empty", exp: "min(empty)", wantErr: "min of empty list"},
+		{name: "floor ceil round", exp: "floor(price) + ceil(price) + round(price) + round(2)", want: 40.0},
+		{name: "sqrt log", exp: "sqrt(16) + log(1)", want: 4.0},
+		{name: "sqrt negative", exp: "sqrt(-1)", wantErr: "sqrt of negative number -1"},
+		{name: "len", exp: "len(trim(name)) + len('中文') + len(tags) + len(mixed)", want: int64(18)},
+		{name: "len literal", exp: "len([1, 2]) + len(['a']) + len([])", want: int64(3)},
+		{name: "len wrong type", exp: "len(1)", wantErr: "len argument 1 expects string or list, got int"},
+		{name: "case", exp: "lower(trim(name)) + upper('x')", want: "alice smithX"},
+		{name: "contains", exp: "contains(name, 'Smith') && startsWith(trim(name), 'Al') && !endsWith(name, 'h')", want: true},
+		{name: "split join", exp: "join(split('a,b,c', ','), '-')", want: "a-b-c"},
+		{name: "join literal", exp: "join(['a', 'b'], ',') + join([1], '-')", want: "a,b1"},
+		{name: "join numbers", exp: "join(mixed, ',')", want: "1,2.5,true"},
+		{name: "replace", exp: "replace('a-b-c', '-', '+')", want: "a+b+c"},
+		{name: "substr", exp: "substr('你好世界', 1, 2) + substr('hello', 3) + substr('ab', 1, 10)", want: "好世lob"},
+		{name: "substr out of range", exp: "substr('ab', 3)", wantErr: "substr start 3 out of range [0, 2]"},
+		{name: "substr float index", exp: "substr('ab', 0.5)", wantErr: "substr argument 2 expects integer, got number"},
+		{name: "int", exp: "int('42') + int(' 2.9 ') + int(3.7) + int(true)", want: int64(48)},
+		{name: "int invalid", exp: "int('x')", wantErr: `int: cannot parse "x"`},
+		{name: "float", exp: "float('1.5') + float(1)", want: 2.5},
+		{name: "string numbers", exp: "string(1) + string(0.1) + string(1000000.0) + string(false)", want: "10.11000000false"},
+		{name: "string list", exp: "string([1])", wantErr: "string argument 1 expects number, string or bool, got list"},
+		{name: "max literal", exp: "max([3, 1, 2]) + min([4], 5)", wantErr: "min argument 1 expects number, got list"},
+		{name: "bool", exp: "bool('true') && bool(1) && !bool(0.0)", want: true},
+		{name: "arity", exp: "lower('a', 'b')", wantErr: "lower expects 1 arguments, got 2"},
+		{name: "argument type", exp: "upper(1)", wantErr: "upper argument 1 expects string, got int"},
+		{name: "name as variable", exp: "max + 1", want: int64(8)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkExecute(t, tt.exp, tt.want, tt.wantErr, params, nil, WithStdlib())
+		})
+	}
+}
+
+func TestWithStdlib_Override(t *testing.T) {
+	// 未启用标准库时 len 不是函数
+	if _, err := NewExpression("len('abc')", true, nil); err == nil {
+		t.Error("NewExpression() without WithStdlib error = nil")
+	}
+	functions := map[string]Function{
+		"len": func(params ...any) (any, error) { return int64(len(params[0].(string))), nil }, // 按字节计算
+	}
+	e, err := NewExpression("len('中') + abs(-1)", true, functions, WithStdlib())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := e.Int64(nil); err != nil || got != 4 {
+		t.Errorf("Int64() = %v, %v, want 4", got, err)
+	}
+	if got := e.Functions(); !reflect.DeepEqual(got, []string{"abs", "len"}) {
+		t.Errorf("Functions() = %v, want [abs len]", got)
+	}
+}
+
+func TestWithStdlib_Schema(t *testing.T) {
+	schema := Schema{
+		Vars:  map[string]Type{"name": TypeString, "age": TypeNumber},
+		Funcs: map[string]Signature{"upper": {Params: []Type{TypeAny}, Result: TypeAny}},
+	}
+	tests := []struct {
+		name    string
+		exp     string
+		wantErr string
+	}{
+		{name: "ok", exp: "len(lower(name)) > 3 && sqrt(age) < 10 && contains(name, 'a')"},
+		{name: "argument type", exp: "lower(age) == 'a'", wantErr: "type: line 1, column 1 (offset 0): lower argument 1 expects string, got number"},
+		{name: "result type", exp: "len(name) && true", wantErr: "type: line 1, column 1 (offset 0): invalid operation int && bool"},
+		{name: "declared signature wins", exp: "upper(age) == 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewExpression(tt.exp, true, nil, WithStdlib(), WithSchema(schema))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("NewExpression() error = %v", err)
+				}
+				return
+			}
+			var te *TypeError
+			if !errors.As(err, &te) || err.Error() != tt.wantErr {
+				t.Errorf("NewExpression() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStdlib_Numbers(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   string
+		args []any
+		want any
+	}{
+		{name: "abs min int", fn: "abs", args: []any{int64(math.MinInt64)}, want: nil},
+		{name: "round half away from zero", fn: "round", args: []any{-2.5}, want: -3.0},
+		{name: "int out of range", fn: "int", args: []any{1e19}, want: nil},
+		{name: "float from uint8", fn: "float", args: []any{uint8(2)}, want: 2.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stdlib[tt.fn](tt.args...)
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("%s() = %v, want error", tt.fn, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("%s() = %v, %v, want %v", tt.fn, got, err, tt.want)
+			}
+		})
+	}
+}
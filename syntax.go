@@ -2,12 +2,87 @@ package goexpression
 
 import "fmt"
 
+// nodeKind 抽象语法树节点类型
+type nodeKind int
+
+const (
+	opNode      nodeKind = iota // 操作符, left/right 为操作数, 一元操作符只有 left
+	litNode                     // 字面量, value 为字面量值
+	varNode                     // 变量, value 为变量名
+	funcNode                    // 函数调用, value 为 ContextFunction, name 为函数名, right 为参数
+	commaNode                   // ',' 连接的参数/集合元素
+	indexNode                   // 成员访问与索引, left 为对象, right 为 key, a.b 解析为 a['b'], value 为 true 时为可选链 a?.b
+	badNode                     // 错误恢复模式下无法解析的部分
+	assignNode                  // 赋值, left 为变量, right 为值, op 为复合赋值的运算符, = 为 NotOperator
+	seqNode                     // ';' 分隔的语句, 依次执行 left 与 right, 结果为 right
+	lambdaNode                  // lambda, value 为参数名 []string, left 为函数体
+	builtinNode                 // 内置集合函数调用, value 为 builtin, name 为函数名, right 为参数
+	ternaryNode                 // 三元表达式 a ? b : c, left 为条件, right 为 commaNode 连接的两个分支
+	listNode                    // 集合字面量 [a, b], left 为 ',' 连接的元素, 作为函数参数时不会被展开
+)
+
 // astNode 抽象语法树节点
 type astNode struct {
 	left, right *astNode
+	kind        nodeKind
 	op          Operator
-	opFunc      opFunc
-	typeCheck   typeCheck
+	value       any
+	name        string
+	span        Span // 节点对应的源码区间
+}
+
+// commaItems 展开 ',' 连接的节点, a, b, c 解析为 ((a, b), c), 展开为 [a, b, c]
+func commaItems(node *astNode) []*astNode {
+	var items []*astNode
+	for ; node != nil && node.kind == commaNode; node = node.left {
+		items = append(items, node.right)
+	}
+	items = append(items, node)
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+	return items
+}
+
+// builtinArgs 内置函数的参数, 参数以 commaNode 向右连接: left 为参数, right 为之后的参数
+// 不同于函数调用, 集合字面量参数不会被展开
+func builtinArgs(node *astNode) []*astNode {
+	var args []*astNode
+	for arg := node.right; arg != nil; arg = arg.right {
+		args = append(args, arg.left)
+	}
+	return args
+}
+
+// ternaryBranches 三元表达式的两个分支, 条件为 true 时执行 then, 否则执行 els
+func ternaryBranches(node *astNode) (then, els *astNode) {
+	return node.right.left, node.right.right
+}
+
+// treeDepth 语法树的深度及最深的节点, 迭代遍历以免过深的树耗尽栈
+func treeDepth(root *astNode) (int, *astNode) {
+	type item struct {
+		node  *astNode
+		depth int
+	}
+	var (
+		max     int
+		deepest *astNode
+		stack   = []item{{root, 1}}
+	)
+	for len(stack) > 0 {
+		it := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if it.node == nil {
+			continue
+		}
+		if it.depth > max {
+			max, deepest = it.depth, it.node
+		}
+		// 先遍历左子树, 深度相同时报告最左侧的节点
+		stack = append(stack, item{it.node.right, it.depth + 1}, item{it.node.left, it.depth + 1})
+	}
+	return max, deepest
 }
 
 func (a *astNode) dumpASTNode() {
@@ -22,16 +97,39 @@ func (a *astNode) dumpASTNode() {
 // parse 语法分析器, 非并发安全, 不可重复利用, 只能解析一个表达式
 type parse struct {
 	*lexer
-	root     *astNode
-	curIndex int
+	root       *astNode
+	curIndex   int
+	depth      int // 当前 unaryExpr 的嵌套深度
+	ternaries  int // 正在解析的三元表达式中尚未遇到 : 的个数
+	config     *config
+	functions  map[string]ContextFunction // 可调用的函数, 包括标准库函数
+	registered map[string]Function        // 注册的函数名, 值无意义
 }
 
 // newParse 创建Parse
-func newParse(raw string) *parse {
-	return &parse{
-		lexer: newLexer(raw),
-		root:  &astNode{},
+func newParse(raw string, cfg *config) *parse {
+	p := &parse{
+		lexer:  newLexer(raw),
+		root:   &astNode{},
+		config: cfg,
 	}
+	p.recovering = cfg.allErrors
+	return p
+}
+
+// errorf 生成携带源码位置的语法错误
+func (p *parse) errorf(span Span, format string, args ...any) *SyntaxError {
+	return &SyntaxError{ErrorPos: newErrorPos(p.Raw, span), Msg: fmt.Sprintf(format, args...)}
+}
+
+// limitError 生成携带源码位置的资源限制错误
+func (p *parse) limitError(span Span, err error, limit int) *LimitError {
+	return &LimitError{ErrorPos: newErrorPos(p.Raw, span), Err: err, Limit: limit}
+}
+
+// endSpan 表达式末尾的空区间, 用于表达式意外结束的错误
+func (p *parse) endSpan() Span {
+	return Span{len(p.Raw), len(p.Raw)}
 }
 
 func (p *parse) curToken() *Token {
@@ -57,7 +155,37 @@ func (p *parse) pre() *Token {
 }
 
 // OnceParse 语法分析, 表达式只需要一次分析
+// 错误恢复模式下各阶段记录错误后继续, 最终返回不完整的语法树与收集到的全部错误 ErrorList
 func (p *parse) OnceParse(functions map[string]Function) (*astNode, error) {
+	if max := p.config.limits.MaxLength; max > 0 && len(p.Raw) > max {
+		return nil, p.limitError(Span{max, len(p.Raw)}, ErrLengthLimit, max)
+	}
+	// Function 与 ContextFunction 统一按 ContextFunction 调用, 注册的同名函数覆盖标准库函数
+	p.functions = make(map[string]ContextFunction, len(functions)+len(p.config.contextFunctions))
+	if p.config.stdlib {
+		for name, function := range stdlib {
+			p.functions[name] = function.withContext()
+		}
+		if p.config.clock != nil {
+			p.functions["now"] = stdNow(p.config.clock).withContext()
+		}
+		p.lexer.stdlib = true
+	}
+	for name, function := range functions {
+		p.functions[name] = function.withContext()
+	}
+	if len(p.config.contextFunctions) > 0 {
+		registered := make(map[string]Function, len(functions)+len(p.config.contextFunctions))
+		for name := range functions {
+			registered[name] = nil
+		}
+		for name, function := range p.config.contextFunctions {
+			p.functions[name], registered[name] = function, nil
+		}
+		functions = registered
+	}
+	p.registered = functions
+	p.lexer.decimal = p.config.decimal != nil
 	if err := p.Parse(functions); err != nil {
 		return nil, err
 	}
@@ -74,77 +202,199 @@ func (p *parse) OnceParse(functions map[string]Function) (*astNode, error) {
 	if err := p.doOnceParse(); err != nil {
 		return nil, err
 	}
+	// 左结合的长运算链如 1 + 1 + ... 不经过递归下降的嵌套, 需检查语法树的深度
+	if max := p.config.limits.MaxDepth; max > 0 {
+		if depth, deepest := treeDepth(p.root); depth > max {
+			return nil, p.limitError(deepest.span, ErrDepthLimit, max)
+		}
+	}
+	if len(p.errs) > 0 {
+		p.errs.sort()
+		return p.root, p.errs
+	}
 	// 提前类型检查
-	// 检查如 1 + true 这种错误
-	// 暂时也不实现(错误将延时在运行时暴露), 应该由用户自行保证这些低级错误不会写出来
-	//if err := p.advanceTypeCheck(); err != nil {
-	//	return nil, err
-	//}
-	// 优化, 暂时不实现, 用户应该写出不需要优化的表达式
-	// 如 1 + 2 + a 就是需要优化的表达式, 用户不应该写出这种表达式
-	// p.optimization()
+	// 检查如 1 + true 这种错误, 只有声明了 Schema 时才检查, 否则错误将延时在运行时暴露
+	if err := p.advanceTypeCheck(); err != nil {
+		return nil, err
+	}
+	// 优化, 如 1 + 2 + a 优化为 3 + a
+	if !p.config.disableOptimization {
+		p.optimization()
+	}
 
 	return p.root, nil
 }
 
+// advanceCheck 检查括号是否配对, 报告未配对的括号
 func (p *parse) advanceCheck() error {
-	var lParenNum, rParenNum, lBrack, rBrack int
+	var opened []*Token
 	for _, token := range p.Tokens {
-		if token.Type == Lparen {
-			lParenNum++
-		}
-		if token.Type == Rparen {
-			rParenNum++
-		}
-		if token.Type == Lbrack {
-			lBrack++
-		}
-		if token.Type == Rbrack {
-			rBrack++
+		switch token.Type {
+		case Lparen, Lbrack:
+			opened = append(opened, token)
+		case Rparen, Rbrack:
+			if len(opened) == 0 || opened[len(opened)-1].Type != openBracket[token.Type] {
+				if err := p.report(p.errorf(token.Span, "unmatched %v", token.Raw)); err != nil {
+					return err
+				}
+				continue
+			}
+			opened = opened[:len(opened)-1]
 		}
 	}
-	if lParenNum != rParenNum {
-		return fmt.Errorf("syntax: ( num != ), please check")
-	}
-	if lBrack != rBrack {
-		return fmt.Errorf("syntax: [ num != ], please check")
+	// 未闭合的括号由内向外报告
+	for i := len(opened) - 1; i >= 0; i-- {
+		token := opened[i]
+		if err := p.report(p.errorf(token.Span, "%v lack of %s", token.Raw, closeBracket(token.Type))); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+var openBracket = map[TokenKind]TokenKind{Rparen: Lparen, Rbrack: Lbrack}
+
+func closeBracket(open TokenKind) string {
+	if open == Lbrack {
+		return "]"
+	}
+	return ")"
+}
+
 func (p *parse) syntaxCheck() error {
 	length := len(p.Tokens)
 	for i := 0; i < length-1; i++ {
-		if !p.Tokens[i].GotTokenKinds()[p.Tokens[i+1].Type] {
-			return fmt.Errorf("syntax: illegal %v after %v", p.Tokens[i+1].Raw, p.Tokens[i].Raw)
+		cur, next := p.Tokens[i], p.Tokens[i+1]
+		if cur.GotTokenKinds()[next.Type] {
+			continue
+		}
+		err := p.errorf(next.Span, "illegal %v after %v", next.Raw, cur.Raw)
+		if cur.Type == Var && next.Type == Lparen {
+			err = p.errorf(cur.Span, "unknown function %v", cur.Raw)
+		}
+		if err := p.report(err); err != nil {
+			return err
 		}
 	}
 	if length > 0 && !p.Tokens[0].CanStart() {
-		return fmt.Errorf("syntax: %v can't start as an expression", p.Tokens[0].Raw)
+		if err := p.report(p.errorf(p.Tokens[0].Span, "%v can't start as an expression", p.Tokens[0].Raw)); err != nil {
+			return err
+		}
 	}
 	if length > 0 && !p.Tokens[length-1].CanEnd() {
-		return fmt.Errorf("syntax: %v can't end as an expression", p.Tokens[length-1].Raw)
+		return p.report(p.errorf(p.Tokens[length-1].Span, "%v can't end as an expression", p.Tokens[length-1].Raw))
 	}
 	return nil
 }
 
-//func (p *parse) advanceTypeCheck() error {
-//	return nil
-//}
+func (p *parse) advanceTypeCheck() error {
+	if p.config.schema == nil {
+		return nil
+	}
+	// 赋值会在 Vars 中记录变量类型, 因此在副本上推导
+	schema := Schema{Vars: make(map[string]Type, len(p.config.schema.Vars)), Funcs: p.config.schema.Funcs}
+	for name, t := range p.config.schema.Vars {
+		schema.Vars[name] = t
+	}
+	// 未被注册函数覆盖且未声明签名的标准库函数按其签名检查
+	if p.config.stdlib {
+		schema.Funcs = make(map[string]Signature, len(stdlibSignatures)+len(p.config.schema.Funcs))
+		for name, sig := range stdlibSignatures {
+			if _, ok := p.registered[name]; !ok {
+				schema.Funcs[name] = sig
+			}
+		}
+		for name, sig := range p.config.schema.Funcs {
+			schema.Funcs[name] = sig
+		}
+	}
+	_, err := schema.infer(p.root)
+	if te, ok := err.(*TypeError); ok {
+		te.ErrorPos = newErrorPos(p.Raw, te.Span)
+	}
+	return err
+}
 
 func (p *parse) doOnceParse() error {
 	var err error
-	p.root, err = p.binaryExpr(nil, 0)
+	p.root, err = p.statements()
 	if err != nil {
 		return err
 	}
-	if !p.end() {
-		return fmt.Errorf("syntax: %v and it after tokens is illegal", p.curToken())
+	// 错误恢复模式下继续解析多余的 Token 以报告其中的错误
+	for !p.end() {
+		stray := p.curToken()
+		if err = p.report(p.errorf(stray.Span, "%v and it after tokens is illegal", stray.Raw)); err != nil {
+			return err
+		}
+		if !stray.CanStart() { // 如多余的 ), 跳过
+			p.next()
+			if p.end() {
+				break
+			}
+			if p.curToken().Operator.IsBinaryOperator() { // 如 a ) + 1 中的 + 1, 接在已解析的部分之后
+				if p.root, err = p.binaryExpr(p.root, 0); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		// 如 a b == 1 中的 b == 1, 作为独立的表达式解析
+		if _, err = p.binaryExpr(nil, 0); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// func (p *parse) optimization() {}
+func (p *parse) optimization() {
+	p.root = optimize(p.root, p.config.decimal)
+}
+
+// statements 解析 ';' 分隔的语句, 结果为最后一条语句的值, 允许末尾的 ';'
+// statements = assignExpr { ; assignExpr } [ ; ]
+func (p *parse) statements() (*astNode, error) {
+	left, err := p.assignExpr()
+	if err != nil {
+		return nil, err
+	}
+	for !p.end() && p.curToken().Type == Semicolon {
+		p.next() // ;
+		if p.end() {
+			break
+		}
+		parent := &astNode{kind: seqNode, left: left}
+		if parent.right, err = p.assignExpr(); err != nil {
+			return nil, err
+		}
+		parent.span = left.span.join(parent.right.span)
+		left = parent
+	}
+	return left, nil
+}
+
+// assignExpr 解析赋值, 赋值为右结合, a = b = 1 等价于 a = (b = 1)
+// assignExpr = binaryExpr [ assign_op assignExpr ]
+func (p *parse) assignExpr() (*astNode, error) {
+	left, err := p.binaryExpr(nil, 0)
+	if err != nil || p.end() || p.curToken().Type != Assign {
+		return left, err
+	}
+	assign := p.curToken()
+	if left.kind != varNode {
+		if left, err = p.bad(p.errorf(left.span, "cannot assign to %s", p.Raw[left.span.Start:left.span.End])); err != nil {
+			return nil, err
+		}
+	}
+	p.next() // = 或复合赋值
+	raw := assign.Raw.(string)
+	parent := &astNode{kind: assignNode, op: opMap[raw[:len(raw)-1]], left: left}
+	if parent.right, err = p.assignExpr(); err != nil {
+		return nil, err
+	}
+	parent.span = left.span.join(parent.right.span)
+	return parent, nil
+}
 
 // binaryExprs 解析表达式列表, 表达式用 ',' 分割
 func (p *parse) binaryExprs(end *Token) (*astNode, error) {
@@ -161,7 +411,7 @@ func (p *parse) binaryExprs(end *Token) (*astNode, error) {
 	}
 	for !p.end() && p.curToken().Type == Comma {
 		parent := &astNode{
-			opFunc: commaFunc,
+			kind: commaNode,
 			// Type Check 由 func/in node 完成
 		}
 		p.next() // ,
@@ -170,6 +420,7 @@ func (p *parse) binaryExprs(end *Token) (*astNode, error) {
 		if err != nil {
 			return nil, err
 		}
+		parent.span = left.span.join(parent.right.span)
 		left = parent
 	}
 	return left, nil
@@ -189,28 +440,92 @@ func (p *parse) binaryExpr(left *astNode, prec int) (*astNode, error) {
 
 	for curToken := p.curToken(); curToken != nil && curToken.Operator.IsBinaryOperator() &&
 		curToken.Operator.GetPrec() > prec; curToken = p.curToken() {
-		parent := &astNode{
-			opFunc:    opFuncArray[curToken.Operator],
-			typeCheck: typeCheckArray[curToken.Operator],
-			op:        curToken.Operator,
+		switch curToken.Operator {
+		case TernaryT:
+			if left, err = p.ternary(left); err != nil {
+				return nil, err
+			}
+			continue
+		case TernaryF:
+			// : 由所属的三元表达式解析
+			if p.ternaries > 0 {
+				return left, nil
+			}
+			if left, err = p.strayColon(left); err != nil {
+				return nil, err
+			}
+			continue
 		}
+		parent := &astNode{op: curToken.Operator}
 		curPrec := curToken.Operator.GetPrec()
+		if curToken.Operator == Coalesce { // ?? 为右结合, a ?? b ?? c 中 b 不存在时同样视为 nil
+			curPrec--
+		}
 		p.next() // op
 		parent.left = left
 		parent.right, err = p.binaryExpr(nil, curPrec)
 		if err != nil {
 			return nil, err
 		}
+		parent.span = left.span.join(parent.right.span)
 		left = parent
 	}
 	return left, nil
 }
 
+// ternary 解析以 cond 为条件的三元表达式, 当前 Token 为 ?
+// 三元表达式为右结合, a ? b : c ? d : e 等价于 a ? b : (c ? d : e), a ? b ? 1 : 2 : 3 中 b ? 1 : 2 为 then 分支
+// ternary = binaryExpr ? binaryExpr : binaryExpr
+func (p *parse) ternary(cond *astNode) (*astNode, error) {
+	question := p.curToken()
+	p.next() // ?
+	p.ternaries++
+	then, err := p.binaryExpr(nil, 0)
+	p.ternaries--
+	if err != nil {
+		return nil, err
+	}
+	if p.end() || p.curToken().Operator != TernaryF {
+		if err = p.report(p.errorf(question.Span, "? lack of :")); err != nil {
+			return nil, err
+		}
+		return &astNode{kind: badNode, span: cond.span.join(then.span)}, nil
+	}
+	p.next() // :
+	els, err := p.binaryExpr(nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	branches := &astNode{kind: commaNode, left: then, right: els, span: then.span.join(els.span)}
+	return &astNode{kind: ternaryNode, op: TernaryT, left: cond, right: branches, span: cond.span.join(els.span)}, nil
+}
+
+// strayColon 报告没有对应 ? 的 :, 错误恢复模式下解析 : 之后的部分并与 left 一起作为 badNode
+func (p *parse) strayColon(left *astNode) (*astNode, error) {
+	colon := p.curToken()
+	if err := p.report(p.errorf(colon.Span, ": without ?")); err != nil {
+		return nil, err
+	}
+	p.next() // :
+	right, err := p.binaryExpr(nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &astNode{kind: badNode, span: left.span.join(right.span)}, nil
+}
+
 // unaryExpr 解析可能携带一元操作符的表达式
 // unaryExpr = primaryExpr | unary_op unaryExpr
 func (p *parse) unaryExpr() (*astNode, error) {
 	if p.end() {
-		return nil, fmt.Errorf("syntax: need unaryExpr, expression premature end")
+		return p.bad(p.errorf(p.endSpan(), "need unaryExpr, expression premature end"))
+	}
+	// 括号、一元运算等的嵌套都经过 unaryExpr, 在此限制递归深度以免耗尽栈
+	if max := p.config.limits.MaxDepth; max > 0 {
+		if p.depth++; p.depth > max {
+			return nil, p.limitError(p.curToken().Span, ErrDepthLimit, max)
+		}
+		defer func() { p.depth-- }()
 	}
 	var (
 		curToken = p.curToken()
@@ -219,26 +534,19 @@ func (p *parse) unaryExpr() (*astNode, error) {
 
 	if curToken.Operator.IsOperator() {
 		switch curToken.Operator {
-		case AddAdd, SubSub, Not, BitNot:
-			parent := &astNode{
-				opFunc:    opFuncArray[curToken.Operator],
-				typeCheck: typeCheckArray[curToken.Operator],
-				op:        curToken.Operator,
+		case AddAdd, SubSub, Not, BitNot, Sub:
+			parent := &astNode{op: curToken.Operator}
+			if curToken.Operator == Sub { // Sub == Minus
+				parent.op = Minus
 			}
 			p.next()
-			parent.left, err = p.unaryExpr()
-			return parent, err
-		case Sub: // Sub == Minus
-			parent := &astNode{
-				opFunc:    opFuncArray[Minus],
-				typeCheck: typeCheckArray[Minus],
-				op:        Minus,
+			if parent.left, err = p.unaryExpr(); err != nil {
+				return nil, err
 			}
-			p.next()
-			parent.left, err = p.unaryExpr()
-			return parent, err
+			parent.span = curToken.Span.join(parent.left.span)
+			return parent, nil
 		default:
-			return nil, fmt.Errorf("syntax: parse unaryExpr illegal operator %v", curToken.Raw)
+			return p.bad(p.errorf(curToken.Span, "parse unaryExpr illegal operator %v", curToken.Raw))
 		}
 	}
 
@@ -246,53 +554,128 @@ func (p *parse) unaryExpr() (*astNode, error) {
 }
 
 // primaryExpr 基本表达式
-// primaryExpr = Lit | Var | Func | ( binaryExpr ) | (unaryExpr, unaryExpr...) | [ unaryExpr, unaryExpr.... ]
+// primaryExpr = Lit | operand { . Var | [ binaryExpr ] }
+// operand = Var | Func | ( binaryExpr ) | [ unaryExpr, unaryExpr.... ]
 func (p *parse) primaryExpr() (*astNode, error) {
 	if p.end() {
-		return nil, fmt.Errorf("syntax: need primaryExpr, expression premature end")
+		return p.bad(p.errorf(p.endSpan(), "need primaryExpr, expression premature end"))
+	}
+	switch p.curToken().Type {
+	case BoolLit, StrLit, FloatLit, IntLit, DurationLit:
+		ret := &astNode{kind: litNode, value: p.curToken().Raw, span: p.curToken().Span}
+		p.next() // Lit
+		return ret, nil
+	case NullLit:
+		ret := &astNode{kind: litNode, span: p.curToken().Span}
+		p.next() // null
+		return ret, nil
+	}
+	operand, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	return p.postfixExpr(operand)
+}
+
+// postfixExpr 解析成员访问与索引, 如 a.b[0].c, 可选链 a?.b、a?.[0]
+func (p *parse) postfixExpr(operand *astNode) (*astNode, error) {
+	for !p.end() {
+		switch p.curToken().Type {
+		case OptionalDot:
+			dot := p.curToken()
+			p.next() // ?.
+			if !p.end() && p.curToken().Type == Lbrack {
+				lbrack := p.curToken()
+				p.next() // [
+				key, err := p.binaryExpr(nil, 0)
+				if err != nil {
+					return nil, err
+				}
+				rbrack, err := p.closeBy(lbrack)
+				if err != nil {
+					return nil, err
+				}
+				operand = &astNode{kind: indexNode, left: operand, right: key, value: true, span: operand.span.join(rbrack)}
+				continue
+			}
+			if p.end() || p.curToken().Type != Var {
+				return operand, p.report(p.errorf(dot.Span, "?. after need field name or ["))
+			}
+			key := &astNode{kind: litNode, value: p.curToken().Raw, span: p.curToken().Span}
+			operand = &astNode{kind: indexNode, left: operand, right: key, value: true, span: operand.span.join(key.span)}
+			p.next() // field name
+		case Dot:
+			dot := p.curToken()
+			p.next() // .
+			if p.end() || p.curToken().Type != Var {
+				return operand, p.report(p.errorf(dot.Span, ". after need field name"))
+			}
+			key := &astNode{kind: litNode, value: p.curToken().Raw, span: p.curToken().Span}
+			operand = &astNode{kind: indexNode, left: operand, right: key, span: operand.span.join(key.span)}
+			p.next() // field name
+		case Lbrack:
+			lbrack := p.curToken()
+			p.next() // [
+			key, err := p.binaryExpr(nil, 0)
+			if err != nil {
+				return nil, err
+			}
+			rbrack, err := p.closeBy(lbrack)
+			if err != nil {
+				return nil, err
+			}
+			operand = &astNode{kind: indexNode, left: operand, right: key, span: operand.span.join(rbrack)}
+		default:
+			return operand, nil
+		}
 	}
+	return operand, nil
+}
+
+// operand 可以进行成员访问与索引的表达式
+func (p *parse) operand() (*astNode, error) {
 	var (
 		curToken = p.curToken()
-		ret      = &astNode{}
+		ret      *astNode
 		err      error
 	)
+	// 括号内的 : 不属于括号外的三元表达式
+	ternaries := p.ternaries
+	p.ternaries = 0
+	defer func() { p.ternaries = ternaries }()
 
 	switch curToken.Type {
-	case BoolLit, StrLit, FloatLit:
-		ret.opFunc = makeLitFunc(curToken.Raw)
-		p.next() // Lit
-		return ret, nil
 	case Var:
-		ret.opFunc = makeVarFunc(curToken.Raw.(string))
+		if _, n := p.lambdaHead(); n > 0 {
+			return p.strayLambda()
+		}
+		if p.curIndex+1 < len(p.Tokens) && p.Tokens[p.curIndex+1].Type == Lparen {
+			// 未注册的函数, 错误恢复模式下按函数调用继续解析
+			if err = p.report(p.errorf(curToken.Span, "unknown function %v", curToken.Raw)); err != nil {
+				return nil, err
+			}
+			return p.call()
+		}
+		ret = &astNode{kind: varNode, value: curToken.Raw.(string), span: curToken.Span}
 		p.next() // var
 		return ret, nil
 	case Func:
-		ret.opFunc = makeFuncFunc(curToken.Raw.(Function))
-		p.next() // func name
-		// 虽然已经在状态转移检查中做过了, 但是为了保证语法解析完整性, 随时可以去掉状态检查, 状态转移只是提前检查
-		if p.end() || p.curToken().Type != Lparen {
-			return nil, fmt.Errorf("syntax: func after need ( ")
-		}
-		p.next() // (
-		ret.right, err = p.binaryExprs(&Token{Type: Rparen})
-		if err != nil {
-			return nil, err
+		if _, ok := p.functions[curToken.Raw.(string)]; ok {
+			return p.call()
 		}
-		if p.end() || p.curToken().Type != Rparen {
-			return nil, fmt.Errorf("syntax: ( lack of ) ")
-		}
-		p.next() // )
-		return ret, err
+		return p.builtinCall()
 	case Lparen:
-		p.next() // (
-		ret, err = p.binaryExpr(nil, 0)
+		if _, n := p.lambdaHead(); n > 0 {
+			return p.strayLambda()
+		}
+		p.next()                  // (
+		ret, err = p.assignExpr() // 允许括号内赋值, 如 ok && (n = n + 1)
 		if err != nil {
 			return nil, err
 		}
-		if p.end() || p.curToken().Type != Rparen {
-			return nil, fmt.Errorf("syntax: ( lack of ) ")
+		if _, err = p.closeBy(curToken); err != nil {
+			return nil, err
 		}
-		p.next() // )
 		return ret, nil
 	case Lbrack:
 		p.next() // [
@@ -300,12 +683,187 @@ func (p *parse) primaryExpr() (*astNode, error) {
 		if err != nil {
 			return nil, err
 		}
-		if p.end() || p.curToken().Type != Rbrack {
-			return nil, fmt.Errorf("syntax: [ lack of ] ")
+		rbrack, err := p.closeBy(curToken)
+		if err != nil {
+			return nil, err
 		}
-		p.next() // ]
-		return ret, nil
+		if ret == nil { // [] 的执行结果为 nil
+			return &astNode{kind: litNode, span: curToken.Span.join(rbrack)}, nil
+		}
+		return &astNode{kind: listNode, left: ret, span: curToken.Span.join(rbrack)}, nil
 	default:
-		return nil, fmt.Errorf("syntax: primaryExpr illegal Token %v", curToken)
+		return p.bad(p.errorf(curToken.Span, "operand illegal Token %v", curToken.Raw))
+	}
+}
+
+// call 解析函数调用, 当前 Token 为函数名
+func (p *parse) call() (*astNode, error) {
+	name := p.curToken()
+	ret := &astNode{kind: funcNode, name: name.Raw.(string), span: name.Span}
+	ret.value = p.functions[ret.name]
+	p.next() // func name
+	// 虽然已经在状态转移检查中做过了, 但是为了保证语法解析完整性, 随时可以去掉状态检查, 状态转移只是提前检查
+	if p.end() || p.curToken().Type != Lparen {
+		return ret, p.report(p.errorf(name.Span, "func after need ("))
+	}
+	lparen := p.curToken()
+	p.next() // (
+	var err error
+	if ret.right, err = p.binaryExprs(&Token{Type: Rparen}); err != nil {
+		return nil, err
+	}
+	rparen, err := p.closeBy(lparen)
+	if err != nil {
+		return nil, err
+	}
+	ret.span = name.Span.join(rparen)
+	return ret, nil
+}
+
+// builtinCall 解析内置集合函数调用, 当前 Token 为函数名, 参数可以是 lambda
+// builtinCall = builtin ( argument { , argument } )
+func (p *parse) builtinCall() (*astNode, error) {
+	name := p.curToken()
+	ret := &astNode{kind: builtinNode, name: name.Raw.(string), span: name.Span}
+	id := builtins[ret.name]
+	ret.value = id
+	p.next() // builtin name
+	if p.end() || p.curToken().Type != Lparen {
+		return ret, p.report(p.errorf(name.Span, "func after need ("))
+	}
+	lparen := p.curToken()
+	p.next() // (
+	var (
+		args []*astNode
+		tail = ret
+	)
+	for !p.end() && p.curToken().Type != Rparen {
+		arg, err := p.argument()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		tail.right = &astNode{kind: commaNode, left: arg, span: arg.span}
+		tail = tail.right
+		if p.end() || p.curToken().Type != Comma {
+			break
+		}
+		p.next() // ,
+	}
+	rparen, err := p.closeBy(lparen)
+	if err != nil {
+		return nil, err
+	}
+	ret.span = name.Span.join(rparen)
+
+	// 参数为集合、lambda 与 reduce 可选的初始值
+	maxArgs := 2
+	if id == builtinReduce {
+		maxArgs = 3
+	}
+	if len(args) < 2 || len(args) > maxArgs {
+		want := "2"
+		if maxArgs > 2 {
+			want = "2 or 3"
+		}
+		return p.bad(p.errorf(ret.span, "%s expects %s arguments, got %d", ret.name, want, len(args)))
+	}
+	for i, arg := range args {
+		if i == 1 {
+			if arg.kind != lambdaNode || len(arg.value.([]string)) != id.lambdaParams() {
+				return p.bad(p.errorf(arg.span, "%s argument 2 must be a lambda with %d parameter(s)", ret.name, id.lambdaParams()))
+			}
+		} else if arg.kind == lambdaNode {
+			return p.bad(p.errorf(arg.span, "%s argument %d cannot be a lambda", ret.name, i+1))
+		}
+	}
+	return ret, nil
+}
+
+// argument 解析内置函数的参数
+// argument = lambda | binaryExpr
+func (p *parse) argument() (*astNode, error) {
+	if _, n := p.lambdaHead(); n > 0 {
+		return p.lambda()
+	}
+	return p.binaryExpr(nil, 0)
+}
+
+// lambdaHead 当前位置是否为 lambda 的参数列表, 返回参数名与参数列表(含 =>)的 Token 数, 不是 lambda 时 n 为 0
+// 参数列表为 x => 或 (x, y) =>
+func (p *parse) lambdaHead() (params []string, n int) {
+	tokens := p.Tokens[p.curIndex:]
+	if len(tokens) >= 2 && tokens[0].Type == Var && tokens[1].Type == Arrow {
+		return []string{tokens[0].Raw.(string)}, 2
+	}
+	if len(tokens) == 0 || tokens[0].Type != Lparen {
+		return nil, 0
+	}
+	for i := 1; i+1 < len(tokens); i += 2 {
+		if tokens[i].Type != Var {
+			return nil, 0
+		}
+		params = append(params, tokens[i].Raw.(string))
+		switch tokens[i+1].Type {
+		case Comma:
+			continue
+		case Rparen:
+			if i+2 < len(tokens) && tokens[i+2].Type == Arrow {
+				return params, i + 3
+			}
+		}
+		return nil, 0
+	}
+	return nil, 0
+}
+
+// lambda 解析 lambda, 函数体中可以赋值, 如 x => total += x
+// lambda = ( Var | ( Var { , Var } ) ) => assignExpr
+func (p *parse) lambda() (*astNode, error) {
+	start := p.curToken()
+	params, n := p.lambdaHead()
+	p.curIndex += n
+	ret := &astNode{kind: lambdaNode, value: params}
+	var err error
+	if ret.left, err = p.assignExpr(); err != nil {
+		return nil, err
+	}
+	ret.span = start.Span.join(ret.left.span)
+	return ret, nil
+}
+
+// strayLambda 报告不在内置函数参数中的 lambda, 错误恢复模式下跳过整个 lambda 继续解析
+func (p *parse) strayLambda() (*astNode, error) {
+	start := p.curToken()
+	if err := p.report(p.errorf(start.Span, "lambda can only be an argument of built-in collection functions")); err != nil {
+		return nil, err
+	}
+	node, err := p.lambda()
+	if err != nil {
+		return nil, err
+	}
+	return &astNode{kind: badNode, span: node.span}, nil
+}
+
+// closeBy 跳过与 open 配对的右括号并返回其区间
+// 缺少右括号时报告错误, 错误恢复模式下返回 open 的区间继续解析
+func (p *parse) closeBy(open *Token) (Span, error) {
+	want := Rparen
+	if open.Type == Lbrack {
+		want = Rbrack
+	}
+	if p.end() || p.curToken().Type != want {
+		return open.Span, p.report(p.errorf(open.Span, "%v lack of %s", open.Raw, closeBracket(open.Type)))
+	}
+	span := p.curToken().Span
+	p.next()
+	return span, nil
+}
+
+// bad 报告无法解析的部分, 错误恢复模式下以 badNode 代替
+func (p *parse) bad(err *SyntaxError) (*astNode, error) {
+	if err := p.report(err); err != nil {
+		return nil, err
 	}
+	return &astNode{kind: badNode, span: err.Span}, nil
 }
@@ -0,0 +1,114 @@
+package goexpression
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExecute_Ternary(t *testing.T) {
+	var calls []string
+	functions := map[string]Function{
+		"none": func(params ...any) (any, error) { return nil, nil },
+		"mark": func(params ...any) (any, error) {
+			calls = append(calls, params[0].(string))
+			return params[0], nil
+		},
+	}
+	params := map[string]any{"a": true, "b": false, "n": 5, "s": "x"}
+	tests := []struct {
+		name      string
+		exp       string
+		want      any
+		wantCalls []string
+		wantErr   string
+	}{
+		{name: "nil then", exp: "a ? none() : 'x'", want: nil},
+		{name: "nil then literal list", exp: "a ? [] : 'x'", want: nil},
+		{name: "else", exp: "b ? 1 : 2", want: int64(2)},
+		{name: "nested then", exp: "a ? b ? 1 : 2 : 3", want: int64(2)},
+		{name: "nested else", exp: "b ? 1 : a ? 2 : 3", want: int64(2)},
+		{name: "chain", exp: "n > 10 ? 'big' : n > 3 ? 'mid' : 'small'", want: "mid"},
+		{name: "lower precedence than or", exp: "b || a ? 1 : 2", want: int64(1)},
+		{name: "else takes the rest", exp: "b ? 1 : 2 + 3", want: int64(5)},
+		{name: "operand", exp: "(a ? n : 0) * 2", want: int64(10)},
+		{name: "else not evaluated", exp: "a ? mark('then') : mark('else')", want: "then", wantCalls: []string{"then"}},
+		{name: "then not evaluated", exp: "b ? mark('then') : mark('else')", want: "else", wantCalls: []string{"else"}},
+		{name: "nested branches not evaluated", exp: "a ? (b ? mark('1') : mark('2')) : mark('3')", want: "2", wantCalls: []string{"2"}},
+		{name: "assign in branch", exp: "b ? (k = 0) : (k = n); k", want: int64(5)},
+		{name: "condition not bool", exp: "n ? 1 : 2", wantErr: "non-bool int used as ? condition"},
+		{name: "condition nil", exp: "none() ? 1 : 2", wantErr: "non-bool nil used as ? condition"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			checkExecute(t, tt.exp, tt.want, tt.wantErr, params, functions)
+			if tt.wantErr == "" && !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestTernary_Syntax(t *testing.T) {
+	tests := []struct {
+		name    string
+		exp     string
+		wantErr string
+	}{
+		{name: "missing colon", exp: "a ? 1", wantErr: "syntax: line 1, column 3 (offset 2): ? lack of :"},
+		{name: "stray colon", exp: "a : 1", wantErr: "syntax: line 1, column 3 (offset 2): : without ?"},
+		{name: "extra colon", exp: "a ? 1 : 2 : 3", wantErr: "syntax: line 1, column 11 (offset 10): : without ?"},
+		{name: "colon in brackets", exp: "a ? [1 : 2] : 3", wantErr: "syntax: line 1, column 8 (offset 7): : without ?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewExpression(tt.exp, true, nil)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("NewExpression() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTernary_Node(t *testing.T) {
+	root, errs := ParseAST("a ? b ? 1 : 2 : c ? 3 : 4", nil)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	if root.Kind != TernaryNode || len(root.Children) != 3 {
+		t.Fatalf("ParseAST() = %+v, want TernaryNode with 3 children", root)
+	}
+	if then, els := root.Children[1], root.Children[2]; then.Kind != TernaryNode || els.Kind != TernaryNode {
+		t.Errorf("ParseAST() branches = %v %v, want TernaryNode", then.Kind, els.Kind)
+	}
+	if root.Span != (Span{0, 25}) {
+		t.Errorf("ParseAST() span = %v, want {0 25}", root.Span)
+	}
+}
+
+func TestTernary_Schema(t *testing.T) {
+	schema := Schema{Vars: map[string]Type{"vip": TypeBool, "age": TypeInt, "name": TypeString}}
+	_, err := NewExpression("(age + 1 ? 1 : 2) > 0", true, nil, WithSchema(schema))
+	var te *TypeError
+	if !errors.As(err, &te) || te.Op != TernaryT || !reflect.DeepEqual(te.Operands, []string{"int"}) {
+		t.Fatalf("NewExpression() error = %v, want *TypeError of ?", err)
+	}
+	if want := "type: line 1, column 2 (offset 1): non-bool int used as ? condition"; err.Error() != want {
+		t.Errorf("NewExpression() error = %v, want %s", err, want)
+	}
+	if _, err = NewExpression("(vip ? age : 0.5) - 1 > 0 && (vip ? name : 'x') + 'y' == 'xy'", true, nil, WithSchema(schema)); err != nil {
+		t.Errorf("NewExpression() error = %v", err)
+	}
+}
+
+func TestTernary_NeedCheck(t *testing.T) {
+	e, err := NewExpression("n ? 1 : 2", true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var te *TypeError
+	if _, err = e.Execute(map[string]any{"n": "yes"}); !errors.As(err, &te) || te.Op != TernaryT {
+		t.Errorf("Execute() error = %v, want *TypeError of ?", err)
+	}
+}
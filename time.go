@@ -0,0 +1,182 @@
+package goexpression
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// 时间与时长的运算规则:
+//   - time - time = duration, time ± duration = time, duration + time = time
+//   - duration ± duration = duration, duration * number = duration, duration / number = duration
+//   - duration / duration = number, 如 (now() - created) / 1h 为相差的小时数
+//   - time 之间、duration 之间可以比较大小与相等
+
+var errDurationOverflow = errors.New("execute: duration overflow")
+
+// parseDuration 解析时长, 在 time.ParseDuration 的基础上支持天 d, 如 7d、1d12h、1.5h
+func parseDuration(s string) (time.Duration, error) {
+	orig := s
+	neg := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	if s == "" {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+	var total time.Duration
+	for s != "" {
+		i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if i <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		j := strings.IndexAny(s[i:], "0123456789.")
+		if j < 0 {
+			j = len(s) - i
+		}
+		num, unit := s[:i], s[i:i+j]
+		s = s[i+j:]
+		if unit == "" {
+			return 0, fmt.Errorf("invalid duration %q: missing unit", orig)
+		}
+		scale := time.Duration(1)
+		if unit == "d" {
+			unit, scale = "h", 24
+		}
+		d, err := time.ParseDuration(num + unit)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		if d > math.MaxInt64/scale || d*scale > math.MaxInt64-total {
+			return 0, fmt.Errorf("invalid duration %q: overflow", orig)
+		}
+		total += d * scale
+	}
+	if neg {
+		total = -total
+	}
+	return total, nil
+}
+
+// compareTemporal 比较两个 time 或两个 duration, 返回 -1、0、1
+func compareTemporal(left, right any) (int, bool) {
+	switch l := left.(type) {
+	case time.Time:
+		r, ok := right.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case l.Before(r):
+			return -1, true
+		case l.After(r):
+			return 1, true
+		}
+		return 0, true
+	case time.Duration:
+		r, ok := right.(time.Duration)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case l < r:
+			return -1, true
+		case l > r:
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// addTemporal time + duration、duration + time 与 duration + duration
+func addTemporal(left, right any) (any, bool) {
+	switch l := left.(type) {
+	case time.Time:
+		if r, ok := right.(time.Duration); ok {
+			return l.Add(r), true
+		}
+	case time.Duration:
+		switch r := right.(type) {
+		case time.Duration:
+			return l + r, true
+		case time.Time:
+			return r.Add(l), true
+		}
+	}
+	return nil, false
+}
+
+// subTemporal time - time、time - duration 与 duration - duration
+func subTemporal(left, right any) (any, bool) {
+	switch l := left.(type) {
+	case time.Time:
+		switch r := right.(type) {
+		case time.Time:
+			return l.Sub(r), true
+		case time.Duration:
+			return l.Add(-r), true
+		}
+	case time.Duration:
+		if r, ok := right.(time.Duration); ok {
+			return l - r, true
+		}
+	}
+	return nil, false
+}
+
+// mulDuration duration * number 与 number * duration, 整数倍与 Go 一样溢出时回绕
+func mulDuration(left, right any) (any, bool, error) {
+	d, ok := left.(time.Duration)
+	n := right
+	if !ok {
+		if d, ok = right.(time.Duration); !ok {
+			return nil, false, nil
+		}
+		n = left
+	}
+	if i, ok := asInt64(n); ok {
+		return d * time.Duration(i), true, nil
+	}
+	if f, ok := toFloat64(n); ok {
+		ret, err := floatDuration(float64(d) * f)
+		return ret, true, err
+	}
+	return nil, false, nil
+}
+
+// divDuration duration / number 与 duration / duration
+func divDuration(left, right any) (any, bool, error) {
+	d, ok := left.(time.Duration)
+	if !ok {
+		return nil, false, nil
+	}
+	if r, ok := right.(time.Duration); ok {
+		if r == 0 {
+			return nil, true, errIntegerDivideByZero
+		}
+		return float64(d) / float64(r), true, nil
+	}
+	if i, ok := asInt64(right); ok {
+		if i == 0 {
+			return nil, true, errIntegerDivideByZero
+		}
+		return d / time.Duration(i), true, nil
+	}
+	if f, ok := toFloat64(right); ok {
+		ret, err := floatDuration(float64(d) / f)
+		return ret, true, err
+	}
+	return nil, false, nil
+}
+
+// floatDuration 纳秒数转换为 duration, 超出范围或为 NaN 时返回错误
+func floatDuration(ns float64) (any, error) {
+	if math.IsNaN(ns) || ns >= math.MaxInt64 || ns < math.MinInt64 {
+		return nil, errDurationOverflow
+	}
+	return time.Duration(ns), nil
+}
@@ -0,0 +1,162 @@
+package goexpression
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExecute_Time(t *testing.T) {
+	clock := func() time.Time { return time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC) } // 星期三
+	params := map[string]any{
+		"created": time.Date(2026, 2, 20, 10, 30, 0, 0, time.UTC),
+		"ttl":     36 * time.Hour,
+		"local":   time.Date(2026, 2, 20, 18, 30, 0, 0, time.FixedZone("CST", 8*3600)),
+	}
+	tests := []struct {
+		name    string
+		exp     string
+		want    any
+		wantErr string
+	}{
+		{name: "time minus time", exp: "now() - created", want: 12 * 24 * time.Hour},
+		{name: "older than", exp: "now() - created > 7d && created + 7d < now()", want: true},
+		{name: "duration plus time", exp: "7d + created == created + 168h", want: true},
+		{name: "time minus duration", exp: "now() - 12d == created", want: true},
+		{name: "duration ratio", exp: "(now() - created) / 1d", want: 12.0},
+		{name: "duration scale", exp: "ttl * 2 == 3d && 2 * ttl == 72h && ttl / 2 == 18h && ttl * 1.5 == 54h", want: true},
+		{name: "duration arithmetic", exp: "ttl - 1d + 30m", want: 12*time.Hour + 30*time.Minute},
+		{name: "negate duration", exp: "-ttl + 36h == 0s && -ttl < 0s", want: true},
+		{name: "duration literals", exp: "1h30m == 90m && 1.5h == 5400s && 500ms * 2 == 1s && 1d12h == ttl", want: true},
+		{name: "equal across zones", exp: "local == created && local >= created", want: true},
+		{name: "business hours", exp: "hour(now()) >= 9 && hour(now()) < 18 && weekday(now()) in [1, 2, 3, 4, 5]", want: true},
+		{name: "fields", exp: "[year(created), month(created), day(created), minute(now()), weekday(created)]", want: []any{int64(2026), int64(2), int64(20), int64(30), int64(5)}},
+		{name: "local fields", exp: "hour(local)", want: int64(18)},
+		{name: "date", exp: "date('2026-02-20 10:30:00') == created && date('2026-02-20T18:30:00+08:00') == created", want: true},
+		{name: "date layout", exp: "date('20/02/2026', '02/01/2006') == date('2026-02-20')", want: true},
+		{name: "format", exp: "format(now()) + ' ' + format(created, '2006/01/02')", want: "2026-03-04T10:30:00Z 2026/02/20"},
+		{name: "duration function", exp: "duration('1d12h') == ttl && duration('-90m') == -1.5h", want: true},
+		{name: "sort times", exp: "sortBy([now(), created], x => x)[0] == created", want: true},
+		{name: "add times", exp: "created + created", wantErr: "invalid operation time + time"},
+		{name: "multiply durations", exp: "ttl * ttl", wantErr: "invalid operation duration * duration"},
+		{name: "compare with number", exp: "created < 1", wantErr: "invalid operation time < int"},
+		{name: "divide by zero", exp: "ttl / 0", wantErr: "integer divide by zero"},
+		{name: "duration overflow", exp: "ttl / 0.0", wantErr: "duration overflow"},
+		{name: "date invalid", exp: "date('tomorrow')", wantErr: `date: cannot parse "tomorrow"`},
+		{name: "duration invalid", exp: "duration('1x')", wantErr: `duration: cannot parse "1x"`},
+		{name: "field of number", exp: "year(1)", wantErr: "year argument 1 expects time, got int"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkExecute(t, tt.exp, tt.want, tt.wantErr, params, nil, WithStdlib(), WithClock(clock))
+		})
+	}
+}
+
+func TestTime_Literal(t *testing.T) {
+	tests := []struct {
+		exp     string
+		want    time.Duration
+		wantErr string
+	}{
+		{exp: "7d", want: 7 * 24 * time.Hour},
+		{exp: "1h30m", want: 90 * time.Minute},
+		{exp: "1.5d", want: 36 * time.Hour},
+		{exp: "250us + 1µs", want: 251 * time.Microsecond},
+		{exp: "1d + 1h", want: 25 * time.Hour},
+		{exp: "7days", wantErr: "syntax: line 1, column 2 (offset 1): illegal days after 7"},
+		{exp: "1h 30m", wantErr: "syntax: line 1, column 4 (offset 3): illegal 30m0s after 1h0m0s"},
+		{exp: "1h30", wantErr: "syntax: line 1, column 2 (offset 1): illegal h30 after 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.exp, func(t *testing.T) {
+			e, err := NewExpression(tt.exp, true, nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("NewExpression() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.root.kind != litNode || e.root.value != tt.want {
+				t.Errorf("root = %+v, want literal %v", e.root, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	// 未替换时钟时为当前时间
+	e, err := NewExpression("now()", true, nil, WithStdlib())
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now()
+	got, err := e.Execute(nil)
+	if now, ok := got.(time.Time); err != nil || !ok || now.Before(before) || now.After(time.Now()) {
+		t.Errorf("Execute() = %v, %v, want current time", got, err)
+	}
+	// 注册的同名函数仍然优先
+	fixed := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	functions := map[string]Function{"now": func(params ...any) (any, error) { return fixed, nil }}
+	e, _ = NewExpression("year(now())", true, functions, WithStdlib(), WithClock(time.Now))
+	if got, err := e.Execute(nil); err != nil || got != int64(2000) {
+		t.Errorf("Execute() = %v, %v, want 2000", got, err)
+	}
+}
+
+func TestTime_Schema(t *testing.T) {
+	schema := Schema{Vars: map[string]Type{"created": TypeTime, "ttl": TypeDuration, "n": TypeInt}}
+	tests := []struct {
+		name    string
+		exp     string
+		wantErr string
+	}{
+		{name: "ok", exp: "now() - created > ttl && (now() - created) / 1h > n && hour(created + ttl * n) < 18"},
+		{name: "add times", exp: "created + created > ttl", wantErr: "type: line 1, column 1 (offset 0): invalid operation time + time"},
+		{name: "compare", exp: "ttl > n", wantErr: "type: line 1, column 1 (offset 0): invalid operation duration > int"},
+		{name: "result type", exp: "now() - created > created", wantErr: "type: line 1, column 1 (offset 0): invalid operation duration > time"},
+		{name: "argument type", exp: "year(ttl) > 1", wantErr: "type: line 1, column 1 (offset 0): year argument 1 expects time, got duration"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewExpression(tt.exp, true, nil, WithStdlib(), WithSchema(schema))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("NewExpression() error = %v", err)
+				}
+				return
+			}
+			var te *TypeError
+			if !errors.As(err, &te) || err.Error() != tt.wantErr {
+				t.Errorf("NewExpression() error = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{s: "1d2h3m4s5ms6us7ns", want: 26*time.Hour + 3*time.Minute + 4*time.Second + 5*time.Millisecond + 6*time.Microsecond + 7},
+		{s: "-1.5d", want: -36 * time.Hour},
+		{s: "", wantErr: true},
+		{s: "h", wantErr: true},
+		{s: "1h0", wantErr: true},
+		{s: "1w", wantErr: true},
+		{s: "106752d", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := parseDuration(tt.s)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseDuration() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
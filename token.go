@@ -7,6 +7,7 @@ type TokenKind int
 
 const (
 	FloatLit TokenKind = iota
+	IntLit
 	StrLit
 	BoolLit
 	Var // var
@@ -16,10 +17,19 @@ const (
 	Lbrack // [
 	Rbrack // ]
 	Comma  // ,
+	Dot    // .
 
 	Op
 
 	Func
+
+	Assign    // = 及 += 等复合赋值
+	Semicolon // ;
+	Arrow     // =>, lambda 的参数与函数体的分隔
+
+	NullLit     // null 与 nil
+	OptionalDot // ?., 对象为 nil 时成员访问链的结果为 nil
+	DurationLit // 时长, 如 7d、36h、1h30m
 )
 
 // Operator 操作符
@@ -36,13 +46,17 @@ const (
 
 	AndAnd // &&
 
-	Eql // ==
-	Neq // !=
-	Lss // <
-	Leq // <=
-	Gtr // >
-	Geq // >=
-	In  // in
+	Eql      // ==
+	Neq      // !=
+	Lss      // <
+	Leq      // <=
+	Gtr      // >
+	Geq      // >=
+	In       // in
+	Match    // =~
+	NotMatch // !~
+
+	Coalesce // ??
 
 	Add // +
 	Sub // -
@@ -89,6 +103,9 @@ var opMap = map[string]Operator{
 	">":  Gtr,
 	">=": Geq,
 	"in": In,
+	"=~": Match,
+	"!~": NotMatch,
+	"??": Coalesce,
 	"+":  Add,
 	"-":  Sub,
 	"|":  Or,
@@ -108,6 +125,19 @@ var opMap = map[string]Operator{
 	// "-": Minus, // 特殊处理
 }
 
+// String 操作符的书写形式
+func (o Operator) String() string {
+	if o == Minus {
+		return "-"
+	}
+	for raw, op := range opMap {
+		if op == o {
+			return raw
+		}
+	}
+	return fmt.Sprintf("Operator(%d)", int(o))
+}
+
 // var opPrec = [OpSize]int{0, 1, 1, 2, 3, 4, 4, 4, 4, 4, 4, 4, 5, 5, 5, 5, 6, 6, 6, 6, 6, 6, 6, 7, 8, 8, 8, 8, 8}
 
 // GetPrec 获取操作符优先级
@@ -134,7 +164,13 @@ func (o Operator) GetPrec() int {
 	case Geq:
 		fallthrough
 	case In:
+		fallthrough
+	case Match:
+		fallthrough
+	case NotMatch:
 		return 4
+	case Coalesce:
+		return 5
 	case Add:
 		fallthrough
 	case Sub:
@@ -142,7 +178,7 @@ func (o Operator) GetPrec() int {
 	case Or:
 		fallthrough
 	case Xor:
-		return 5
+		return 6
 	case Mul:
 		fallthrough
 	case Div:
@@ -156,9 +192,9 @@ func (o Operator) GetPrec() int {
 	case Shl:
 		fallthrough
 	case Shr:
-		return 6
-	case Exponent:
 		return 7
+	case Exponent:
+		return 8
 	case AddAdd:
 		fallthrough
 	case SubSub:
@@ -168,7 +204,7 @@ func (o Operator) GetPrec() int {
 	case Not:
 		fallthrough
 	case BitNot:
-		return 8
+		return 9
 	default:
 		return 0
 	}
@@ -179,6 +215,7 @@ type Token struct {
 	Type     TokenKind
 	Operator Operator
 	Raw      any
+	Span     Span // Token 在源码中的区间
 }
 
 // String return Token's string representation
@@ -188,86 +225,185 @@ func (t *Token) String() string {
 
 var stateTransferMap = map[TokenKind]map[TokenKind]bool{
 	FloatLit: {
-		Op:     true, // 1 + 1
-		Rparen: true, // (1 + 1)
-		Rbrack: true, // [1, 2]
-		Comma:  true, // [1, 1]
+		Op:        true, // 1 + 1
+		Rparen:    true, // (1 + 1)
+		Rbrack:    true, // [1, 2]
+		Comma:     true, // [1, 1]
+		Semicolon: true, // a = 1.5; a
+	},
+	IntLit: {
+		Op:        true, // 1 + 1
+		Rparen:    true,
+		Rbrack:    true,
+		Comma:     true,
+		Semicolon: true,
 	},
 	StrLit: {
-		Op:     true, // '1' + '1'
-		Rparen: true,
-		Rbrack: true,
-		Comma:  true,
+		Op:        true, // '1' + '1'
+		Rparen:    true,
+		Rbrack:    true,
+		Comma:     true,
+		Semicolon: true,
 	},
 	BoolLit: {
-		Op:     true, // true == false
-		Rparen: true,
-		Rbrack: true,
-		Comma:  true,
+		Op:        true, // true == false
+		Rparen:    true,
+		Rbrack:    true,
+		Comma:     true,
+		Semicolon: true,
+	},
+	DurationLit: {
+		Op:        true, // 7d + 1h
+		Rparen:    true,
+		Rbrack:    true,
+		Comma:     true,
+		Semicolon: true,
+	},
+	NullLit: {
+		Op:        true, // a == null
+		Rparen:    true,
+		Rbrack:    true,
+		Comma:     true,
+		Semicolon: true,
 	},
 	Var: {
-		Op:     true, // a == b
-		Rparen: true,
-		Rbrack: true,
-		Comma:  true,
+		Op:          true, // a == b
+		Rparen:      true,
+		Lbrack:      true, // a[0]
+		Rbrack:      true,
+		Comma:       true,
+		Dot:         true, // a.b
+		OptionalDot: true, // a?.b
+		Assign:      true, // a = 1
+		Semicolon:   true,
+		Arrow:       true, // x => x > 1
 	},
 	Lparen: {
-		Op:       true, // (!a & b)
-		FloatLit: true, // (1 + 1)
-		StrLit:   true,
-		BoolLit:  true,
-		Var:      true,
-		Lparen:   true, // ((1+1)+1)
-		Rparen:   true, // func()
-		Lbrack:   true, // ([1, 2])
-		Func:     true,
+		Op:          true, // (!a & b)
+		FloatLit:    true, // (1 + 1)
+		IntLit:      true,
+		StrLit:      true,
+		BoolLit:     true,
+		NullLit:     true,
+		DurationLit: true,
+		Var:         true,
+		Lparen:      true, // ((1+1)+1)
+		Rparen:      true, // func()
+		Lbrack:      true, // ([1, 2])
+		Func:        true,
 	},
 	Rparen: {
-		Op:     true, // (1 + 1) + 1
-		Rparen: true, // (1 + (1 + 1))
-		Rbrack: true,
-		Comma:  true, // [(1+1), (2+1)]
+		Op:          true, // (1 + 1) + 1
+		Rparen:      true, // (1 + (1 + 1))
+		Lbrack:      true, // func()[0]
+		Rbrack:      true,
+		Comma:       true, // [(1+1), (2+1)]
+		Dot:         true, // func().a
+		OptionalDot: true, // func()?.a
+		Assign:      true, // 由语法分析报告 (a) = 1 不可赋值
+		Semicolon:   true,
+		Arrow:       true, // (acc, x) => acc + x
 	},
 	Lbrack: {
-		Op:       true, // [-1, 2]
-		FloatLit: true, // [1, 2]
-		StrLit:   true,
-		BoolLit:  true,
-		Var:      true,
-		Lparen:   true, // [(1+1), 1]
-		Lbrack:   true, // [[1],2]
-		Rbrack:   true, // []
-		Func:     true, // [func(), 2]
+		Op:          true, // [-1, 2]
+		FloatLit:    true, // [1, 2]
+		IntLit:      true,
+		StrLit:      true,
+		BoolLit:     true,
+		NullLit:     true,
+		DurationLit: true,
+		Var:         true,
+		Lparen:      true, // [(1+1), 1]
+		Lbrack:      true, // [[1],2]
+		Rbrack:      true, // []
+		Func:        true, // [func(), 2]
 	},
 	Rbrack: {
-		Op:     true, // 1 in [1, 2, 3] || a > b
-		Rparen: true, // (1 in [1, 2, 3])
-		Rbrack: true, // [2, [1]]
-		Comma:  true, // [[1],2]
+		Op:          true, // 1 in [1, 2, 3] || a > b
+		Rparen:      true, // (1 in [1, 2, 3])
+		Lbrack:      true, // a[0][1]
+		Rbrack:      true, // [2, [1]]
+		Comma:       true, // [[1],2]
+		Dot:         true, // a[0].b
+		OptionalDot: true, // a[0]?.b
+		Assign:      true, // 由语法分析报告 a[0] = 1 不可赋值
+		Semicolon:   true,
 	},
 	Comma: {
-		Op:       true, // [-1, 2]
-		FloatLit: true, // [1, 2]
-		StrLit:   true,
-		BoolLit:  true,
-		Var:      true,
-		Lparen:   true, // [1, (1+1)]
-		Lbrack:   true, //  [2, [1]]
-		Func:     true,
+		Op:          true, // [-1, 2]
+		FloatLit:    true, // [1, 2]
+		IntLit:      true,
+		StrLit:      true,
+		BoolLit:     true,
+		NullLit:     true,
+		DurationLit: true,
+		Var:         true,
+		Lparen:      true, // [1, (1+1)]
+		Lbrack:      true, //  [2, [1]]
+		Func:        true,
 	},
 	Op: {
-		Op:       true, // 1 + -1
-		FloatLit: true,
-		StrLit:   true,
-		BoolLit:  true,
-		Var:      true,
-		Lparen:   true,
-		Lbrack:   true,
-		Func:     true,
+		Op:          true, // 1 + -1
+		FloatLit:    true,
+		IntLit:      true,
+		StrLit:      true,
+		BoolLit:     true,
+		NullLit:     true,
+		DurationLit: true,
+		Var:         true,
+		Lparen:      true,
+		Lbrack:      true,
+		Func:        true,
 	},
 	Func: {
 		Lparen: true, // func()
 	},
+	Dot: {
+		Var: true, // a.b
+	},
+	OptionalDot: {
+		Var:    true, // a?.b
+		Lbrack: true, // a?.[0]
+	},
+	Assign: {
+		Op:          true, // a = -1
+		FloatLit:    true,
+		IntLit:      true,
+		StrLit:      true,
+		BoolLit:     true,
+		NullLit:     true,
+		DurationLit: true,
+		Var:         true,
+		Lparen:      true,
+		Lbrack:      true,
+		Func:        true,
+	},
+	Semicolon: {
+		Op:          true, // a = 1; -a
+		FloatLit:    true,
+		IntLit:      true,
+		StrLit:      true,
+		BoolLit:     true,
+		NullLit:     true,
+		DurationLit: true,
+		Var:         true,
+		Lparen:      true,
+		Lbrack:      true,
+		Func:        true,
+	},
+	Arrow: {
+		Op:          true, // x => !x
+		FloatLit:    true,
+		IntLit:      true,
+		StrLit:      true,
+		BoolLit:     true,
+		NullLit:     true,
+		DurationLit: true,
+		Var:         true,
+		Lparen:      true,
+		Lbrack:      true,
+		Func:        true,
+	},
 }
 
 // GotTokenKinds 当前Token后面期望的Token类型
@@ -277,22 +413,29 @@ func (t *Token) GotTokenKinds() map[TokenKind]bool {
 
 var (
 	startTokens = map[TokenKind]bool{
-		FloatLit: true,
-		StrLit:   true,
-		BoolLit:  true,
-		Var:      true,
-		Lparen:   true,
-		Lbrack:   true,
-		Op:       true,
-		Func:     true,
+		FloatLit:    true,
+		IntLit:      true,
+		StrLit:      true,
+		BoolLit:     true,
+		NullLit:     true,
+		DurationLit: true,
+		Var:         true,
+		Lparen:      true,
+		Lbrack:      true,
+		Op:          true,
+		Func:        true,
 	}
 	endTokens = map[TokenKind]bool{
-		FloatLit: true,
-		StrLit:   true,
-		BoolLit:  true,
-		Var:      true,
-		Rparen:   true,
-		Rbrack:   true,
+		FloatLit:    true,
+		IntLit:      true,
+		StrLit:      true,
+		BoolLit:     true,
+		NullLit:     true,
+		DurationLit: true,
+		Var:         true,
+		Rparen:      true,
+		Rbrack:      true,
+		Semicolon:   true, // 允许末尾的 ;
 	}
 )
 
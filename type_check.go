@@ -1,5 +1,7 @@
 package goexpression
 
+import "regexp"
+
 // typeCheck 类型检查, 检查对应 astNode 左右子节点执行结果是否符合预期
 type typeCheck func(left, right any) bool
 
@@ -19,47 +21,86 @@ var typeCheckArray = [OpSize]typeCheck{
 	canCmp,   // >
 	canCmp,   // >=
 	nil,      // in
-
-	canCmp,    // +
-	isFloat64, // -
-	isFloat64, // |
-	isFloat64, // ^
-
-	isFloat64, // *
-	isFloat64, // /
-	isFloat64, // %
-	isFloat64, // &
-	isFloat64, // &^
-	isFloat64, // <<
-	isFloat64, // >>
-
-	isFloat64, // **
-
-	leftFloat64RightNil, // ++, 注意++和--只设计成只可前置
-	leftFloat64RightNil, // --
-	leftFloat64RightNil, // -
-	leftBoolRightNil,    // !
-	leftFloat64RightNil, // ~
+	canMatch, // =~
+	canMatch, // !~
+
+	nil, // ??
+
+	canAdd,   // +
+	canSub,   // -
+	isNumber, // |
+	isNumber, // ^
+
+	canMul,   // *
+	canDiv,   // /
+	isNumber, // %
+	isNumber, // &
+	isNumber, // &^
+	isNumber, // <<
+	isNumber, // >>
+
+	isNumber, // **
+
+	leftNumberRightNil, // ++, 注意++和--只设计成只可前置
+	leftNumberRightNil, // --
+	canNegate,          // -
+	leftBoolRightNil,   // !
+	leftNumberRightNil, // ~
 }
 
 func canCmp(left, right any) bool {
-	return IsFloat64(left) && IsFloat64(right) || IsString(left) && IsString(right)
+	return IsNumeric(left) && IsNumeric(right) || IsString(left) && IsString(right) ||
+		IsTime(left) && IsTime(right) || IsDuration(left) && IsDuration(right)
+}
+
+// canAdd 数值相加、字符串拼接, time 与 duration 相加, duration 之间相加
+func canAdd(left, right any) bool {
+	return IsNumeric(left) && IsNumeric(right) || IsString(left) && IsString(right) ||
+		IsDuration(right) && (IsTime(left) || IsDuration(left)) || IsTime(right) && IsDuration(left)
+}
+
+// canSub time 减 time 或 duration, duration 之间相减
+func canSub(left, right any) bool {
+	return isNumber(left, right) || IsTime(left) && (IsTime(right) || IsDuration(right)) ||
+		IsDuration(left) && IsDuration(right)
+}
+
+// canMul duration 可以乘以数值
+func canMul(left, right any) bool {
+	return isNumber(left, right) || IsDuration(left) && IsNumeric(right) || IsNumeric(left) && IsDuration(right)
+}
+
+// canDiv duration 可以除以数值或 duration
+func canDiv(left, right any) bool {
+	return isNumber(left, right) || IsDuration(left) && (IsNumeric(right) || IsDuration(right))
 }
 
+func canMatch(left, right any) bool {
+	if _, ok := right.(*regexp.Regexp); ok {
+		return IsString(left)
+	}
+	return IsString(left) && IsString(right)
+}
+
+// eqlOrNeq 任意值都可以与 nil 比较, 如 a == null
 func eqlOrNeq(left, right any) bool {
-	return IsBool(left) && IsBool(right) || canCmp(left, right)
+	return left == nil || right == nil || IsBool(left) && IsBool(right) || canCmp(left, right)
 }
 
 func logic(left, right any) bool {
 	return IsBool(left) && IsBool(right)
 }
 
-func isFloat64(left, right any) bool {
-	return IsFloat64(left) && IsFloat64(right)
+func isNumber(left, right any) bool {
+	return IsNumeric(left) && IsNumeric(right)
+}
+
+func leftNumberRightNil(left, right any) bool {
+	return IsNumeric(left) && right == nil
 }
 
-func leftFloat64RightNil(left, right any) bool {
-	return IsFloat64(left) && right == nil
+func canNegate(left, right any) bool {
+	return (IsNumeric(left) || IsDuration(left)) && right == nil
 }
 
 func leftBoolRightNil(left, right any) bool {
@@ -1,6 +1,9 @@
 package goexpression
 
-import "unicode"
+import (
+	"time"
+	"unicode"
+)
 
 // IsNumber 是否为数字
 func IsNumber(c rune) bool {
@@ -30,6 +33,24 @@ func IsFloat64(value any) bool {
 	return false
 }
 
+// IsInt64 是否是int64类型
+func IsInt64(value any) bool {
+	switch value.(type) {
+	case int64:
+		return true
+	}
+	return false
+}
+
+// IsNumeric 是否是数值类型, 即int64、float64或Decimal
+func IsNumeric(value any) bool {
+	switch value.(type) {
+	case int64, float64, Decimal:
+		return true
+	}
+	return false
+}
+
 // IsBool 是否是bool类型
 func IsBool(value any) bool {
 	switch value.(type) {
@@ -46,3 +67,21 @@ func IsArray(value any) bool {
 	}
 	return false
 }
+
+// IsTime 是否是time.Time类型
+func IsTime(value any) bool {
+	_, ok := value.(time.Time)
+	return ok
+}
+
+// IsDuration 是否是time.Duration类型
+func IsDuration(value any) bool {
+	_, ok := value.(time.Duration)
+	return ok
+}
+
+// IsDecimal 是否是Decimal类型
+func IsDecimal(value any) bool {
+	_, ok := value.(Decimal)
+	return ok
+}
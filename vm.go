@@ -0,0 +1,654 @@
+package goexpression
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// smallStackSize 栈深度不超过该值时使用栈上数组, 避免每次执行分配内存
+const smallStackSize = 8
+
+// slotKind 栈元素类型
+type slotKind uint8
+
+const (
+	refSlot   slotKind = iota // 值保存在 ref 中
+	floatSlot                 // float64, 值保存在 f 中
+	intSlot                   // int64, 值保存在 i 中
+)
+
+// slot 栈元素, 数值不装箱直接保存在 bits 中, 避免中间结果装箱为 any 时的内存分配
+// 来自常量、参数与函数返回值的数值同时在 ref 中保留原始的装箱值, 再次使用时无需重新装箱
+type slot struct {
+	bits uint64 // floatSlot 为 math.Float64bits, intSlot 为 uint64(int64)
+	ref  any
+	kind slotKind
+}
+
+func floatSlotOf(f float64) slot { return slot{bits: math.Float64bits(f), kind: floatSlot} }
+
+func intSlotOf(i int64) slot { return slot{bits: uint64(i), kind: intSlot} }
+
+// slotOf 构造栈元素, 参数与函数返回值在此统一转换为 int64 或 float64
+func slotOf(v any) slot {
+	if n, ok := v.(float64); ok {
+		return slot{bits: math.Float64bits(n), ref: v, kind: floatSlot}
+	}
+	if n, ok := v.(int64); ok {
+		return slot{bits: uint64(n), ref: v, kind: intSlot}
+	}
+	return otherSlotOf(v)
+}
+
+// slotOf 精确小数模式下 float64 与非整数的 json.Number 转换为 Decimal, 其余同 slotOf
+func (p *program) slotOf(v any) slot {
+	if p.decimal != nil {
+		return decimalSlotOf(v)
+	}
+	return slotOf(v)
+}
+
+// decimalSlotOf 开启精确小数模式时的 slotOf, 执行变量读取时直接调用
+func decimalSlotOf(v any) slot {
+	if n, ok := v.(json.Number); ok && strings.ContainsAny(string(n), ".eE") {
+		if d, err := ParseDecimal(string(n)); err == nil {
+			return slot{ref: d}
+		}
+	}
+	s := slotOf(v)
+	if s.kind == floatSlot {
+		if d, ok := DecimalFromFloat(s.float()); ok {
+			return slot{ref: d}
+		}
+	}
+	return s
+}
+
+func otherSlotOf(v any) slot {
+	switch v.(type) {
+	case nil, bool, string, []any:
+		return slot{ref: v}
+	}
+	if n, ok := asNumber(v); ok {
+		return slotOf(n)
+	}
+	return slot{ref: v}
+}
+
+func (s slot) int() int64 { return int64(s.bits) }
+
+// float 数值提升为 float64
+func (s slot) float() float64 {
+	if s.kind == intSlot {
+		return float64(int64(s.bits))
+	}
+	return math.Float64frombits(s.bits)
+}
+
+// value 返回装箱后的值
+func (s slot) value() any {
+	if s.ref != nil {
+		return s.ref
+	}
+	switch s.kind {
+	case floatSlot:
+		return s.float()
+	case intSlot:
+		return s.int()
+	}
+	return nil
+}
+
+// execState 一次执行的状态, 由表达式及其中的 lambda 共享
+type execState struct {
+	ctx       context.Context
+	done      <-chan struct{} // 不可取消的 ctx 返回 nil
+	params    map[string]any
+	needCheck bool
+	writeBack bool
+	checked   bool // ctx 可以取消或限制了指令数时每条指令执行前调用 check, 否则省去检查
+	steps     int
+	maxSteps  int
+	calls     int
+}
+
+// check 检查 ctx 是否已取消以及指令数是否超出限制
+func (st *execState) check() error {
+	if st.done != nil {
+		select {
+		case <-st.done:
+			return st.ctx.Err()
+		default:
+		}
+	}
+	if st.steps++; st.maxSteps > 0 && st.steps > st.maxSteps {
+		return &LimitError{Err: ErrStepLimit, Limit: st.maxSteps}
+	}
+	return nil
+}
+
+// frame 变量作用域, 最外层为本次执行赋值的变量, 第一次赋值时创建; 调用 lambda 时以参数创建内层作用域
+type frame struct {
+	vars   map[string]any
+	parent *frame
+}
+
+// load 由内向外读取变量, 各层作用域都没有时读取 params
+func (st *execState) load(fr *frame, name string) (any, bool) {
+	for ; fr != nil; fr = fr.parent {
+		if fr.vars == nil { // 没有赋值过的最外层作用域
+			continue
+		}
+		if v, ok := fr.vars[name]; ok {
+			return v, true
+		}
+	}
+	v, ok := st.params[name]
+	return v, ok
+}
+
+// store 赋值给定义了该变量的最内层作用域, 都没有定义时赋值给最外层作用域
+// 开启 WithWriteBack 时写入 params, 已有的数值参数尽量保持原有类型
+func (st *execState) store(fr *frame, name string, v any) {
+	for ; fr.parent != nil; fr = fr.parent {
+		if _, ok := fr.vars[name]; ok {
+			fr.vars[name] = v
+			return
+		}
+	}
+	if st.writeBack && st.params != nil {
+		if old, ok := st.params[name]; ok {
+			v = restoreKind(old, v)
+		}
+		st.params[name] = v
+		return
+	}
+	if fr.vars == nil {
+		fr.vars = make(map[string]any)
+	}
+	fr.vars[name] = v
+}
+
+// run 执行编译后的指令序列
+// 所有中间结果保存在栈上, 执行过程不递归, 每次执行使用独立的栈, 因此可以并发执行
+// 执行中的 panic(如函数内部 panic)被转换为 *EvalError, 不会传播到调用方
+// ctx 取消后在下一条指令执行前返回 *EvalError, 可用 errors.Is 检查 ctx.Err()
+// 超出 Limits 的指令数、函数调用次数、结果大小时返回 *LimitError
+// 赋值写入本次执行独立的作用域, 之后读取该变量时优先读取作用域; 开启 WithWriteBack 时直接写入 params
+func (p *program) run(ctx context.Context, params map[string]any, needCheck bool) (any, error) {
+	// 逐个字段赋值, 避免复合字面量先写入临时变量再整体复制
+	var st execState
+	st.ctx, st.done, st.params = ctx, ctx.Done(), params
+	st.needCheck, st.writeBack, st.maxSteps = needCheck, p.writeBack, p.limits.MaxSteps
+	st.checked = st.done != nil || st.maxSteps > 0
+	var root frame
+	return p.exec(&st, &root)
+}
+
+// exec 在作用域 fr 中执行指令序列, 表达式与 lambda 的执行共享 st 中的指令数、函数调用次数
+func (p *program) exec(st *execState, fr *frame) (result any, runErr error) {
+	var (
+		buf   [smallStackSize]slot
+		stack = buf[:0]
+		code  = p.code
+		ret   any
+		err   error
+		pc    int
+	)
+	if p.maxStack > smallStackSize {
+		stack = make([]slot, 0, p.maxStack)
+	}
+	// 变量的值不会改变时, 第 i 个变量第一次读取后保存在 vars[i] 中, loaded 记录已读取的变量
+	var (
+		vars   []slot
+		loaded uint
+	)
+	if p.cacheVars {
+		vars = buf[p.maxStack:]
+	}
+	// site 记录 panic 的位置, pc 与 stack 不被 defer 引用, 可以保存在寄存器中
+	// 每条指令执行前记录 pc, 只在调用函数、操作符等需要报告操作数的指令前记录 stack
+	// 函数内只有少量 return 时 defer 由编译器直接展开, 因此执行错误均跳出循环后统一返回
+	var site struct {
+		pc, at int // 当前指令, 记录 stack 的指令
+		stack  []slot
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			var operands []slot
+			if site.at == site.pc {
+				operands = site.stack
+			}
+			result, runErr = nil, p.panicError(site.pc, operands, r)
+		}
+	}()
+	site.at = -1
+loop:
+	for pc = 0; pc < len(code); pc++ {
+		site.pc = pc
+		if st.checked {
+			if err = st.check(); err != nil {
+				break loop
+			}
+		}
+		ins := code[pc]
+		switch ins.op {
+		case opPush:
+			stack = append(stack, p.consts[ins.arg])
+		case opLoad:
+			if loaded&(1<<ins.arg) != 0 {
+				stack = append(stack, vars[ins.arg])
+				continue
+			}
+			name := p.names[ins.arg]
+			v, ok := st.load(fr, name)
+			if !ok && !p.lenient && ins.argc == 0 {
+				err = fmt.Errorf("execute: %s param not in the passed parameter list", name)
+				break loop
+			}
+			var s slot
+			if p.decimal != nil {
+				s = decimalSlotOf(v)
+			} else {
+				s = slotOf(v)
+			}
+			stack = append(stack, s)
+			// 不存在的变量不缓存, 之后 argc 为 0 的读取仍需报告错误
+			if ok && vars != nil {
+				vars[ins.arg] = s
+				loaded |= 1 << ins.arg
+			}
+		case opCall:
+			if st.calls++; p.limits.MaxCalls > 0 && st.calls > p.limits.MaxCalls {
+				err = &LimitError{Err: ErrCallLimit, Limit: p.limits.MaxCalls}
+				break loop
+			}
+			start := len(stack) - int(ins.argc)
+			site.at, site.stack = pc, stack
+			if ret, err = callFunction(st.ctx, p.funcs[ins.arg], stack[start:]); err != nil {
+				break loop
+			}
+			if err = p.checkSize(ret); err != nil {
+				break loop
+			}
+			stack = append(stack[:start], p.slotOf(ret))
+		case opList:
+			start := len(stack) - int(ins.arg)
+			list := makeList(stack[start:])
+			if err = p.checkSize(list); err != nil {
+				break loop
+			}
+			stack[start] = slot{ref: list}
+			stack = stack[:start+1]
+		case opUnary:
+			top := len(stack) - 1
+			site.at, site.stack = pc, stack
+			if ret, err = p.unary(Operator(ins.arg), stack[top], st.params, st.needCheck); err != nil {
+				break loop
+			}
+			stack[top] = slotOf(ret)
+		case opBinary:
+			top := len(stack) - 1
+			if ins.argc == 0 {
+				if s, ok := fastBinary(Operator(ins.arg), stack[top-1], stack[top]); ok {
+					stack[top-1] = s
+					stack = stack[:top]
+					continue
+				}
+			}
+			site.at, site.stack = pc, stack
+			if ret, err = p.binary(Operator(ins.arg), stack[top-1], stack[top], st.params, st.needCheck); err != nil {
+				break loop
+			}
+			stack[top-1] = slotOf(ret)
+			stack = stack[:top]
+		case opBinaryConst:
+			top := len(stack) - 1
+			if ins.argc == 0 {
+				if s, ok := fastBinary(Operator(ins.arg), stack[top], p.consts[ins.k]); ok {
+					stack[top] = s
+					continue
+				}
+			}
+			site.at, site.stack = pc, stack
+			if ret, err = p.binary(Operator(ins.arg), stack[top], p.consts[ins.k], st.params, st.needCheck); err != nil {
+				break loop
+			}
+			stack[top] = slotOf(ret)
+		case opIndex:
+			top := len(stack) - 1
+			site.at, site.stack = pc, stack
+			if ret, err = lookup(stack[top-1].value(), stack[top].value(), ins.argc == 1); err != nil {
+				break loop
+			}
+			stack[top-1] = p.slotOf(ret)
+			stack = stack[:top]
+		case opIndexConst:
+			top := len(stack) - 1
+			site.at, site.stack = pc, stack
+			if ret, err = lookup(stack[top].value(), p.consts[ins.k].value(), ins.argc == 1); err != nil {
+				break loop
+			}
+			stack[top] = p.slotOf(ret)
+		case opJumpFalse:
+			top := len(stack) - 1
+			if b, ok := stack[top].ref.(bool); ok && !b {
+				pc = int(ins.arg) - 1
+			} else if ins.argc == 1 {
+				stack = stack[:top]
+			}
+		case opJumpTrue:
+			top := len(stack) - 1
+			if b, ok := stack[top].ref.(bool); ok && b {
+				pc = int(ins.arg) - 1
+			} else if ins.argc == 1 {
+				stack = stack[:top]
+			}
+		case opJump:
+			pc = int(ins.arg) - 1
+		case opBranch:
+			top := len(stack) - 1
+			cond, ok := stack[top].ref.(bool)
+			if !ok {
+				err = conditionError(stack[top].value(), st.needCheck)
+				break loop
+			}
+			stack = stack[:top]
+			if !cond {
+				pc = int(ins.arg) - 1
+			}
+		case opJumpNil:
+			if top := stack[len(stack)-1]; top.kind == refSlot && top.ref == nil {
+				pc = int(ins.arg) - 1
+			}
+		case opJumpNotNil:
+			if top := stack[len(stack)-1]; top.kind != refSlot || top.ref != nil {
+				pc = int(ins.arg) - 1
+			}
+		case opStore:
+			st.store(fr, p.names[ins.arg], stack[len(stack)-1].value())
+		case opPop:
+			stack = stack[:len(stack)-1]
+		case opBuiltin:
+			if st.calls++; p.limits.MaxCalls > 0 && st.calls > p.limits.MaxCalls {
+				err = &LimitError{Err: ErrCallLimit, Limit: p.limits.MaxCalls}
+				break loop
+			}
+			start := len(stack) - int(ins.argc)
+			site.at, site.stack = pc, stack
+			if ret, err = p.callBuiltin(st, fr, pc, stack[start:]); err != nil {
+				break loop
+			}
+			if err = p.checkSize(ret); err != nil {
+				break loop
+			}
+			stack = append(stack[:start], p.slotOf(ret))
+		default:
+			return nil, fmt.Errorf("execute: unknown opcode %d", ins.op)
+		}
+	}
+	if err != nil {
+		return nil, p.errorAt(pc, err)
+	}
+	if len(stack) != 1 {
+		return nil, fmt.Errorf("execute: stack unbalanced, depth %d", len(stack))
+	}
+	return stack[0].value(), nil
+}
+
+func (p *program) unary(op Operator, operand slot, params map[string]any, needCheck bool) (any, error) {
+	left := operand.value()
+	if needCheck && typeCheckArray[op] != nil && !typeCheckArray[op](left, nil) {
+		return nil, newOperandTypeError(op, false, typeName(left))
+	}
+	var (
+		ret any
+		err error
+	)
+	if p.decimal != nil {
+		ret, err = p.decimal.operate(op, left, nil, params)
+	} else {
+		ret, err = opFuncArray[op](left, nil, params)
+	}
+	if err != nil {
+		return nil, &EvalError{Op: op, Operands: []string{typeName(left)}, Err: err}
+	}
+	return ret, p.checkSize(ret)
+}
+
+func (p *program) binary(op Operator, l, r slot, params map[string]any, needCheck bool) (any, error) {
+	left, right := l.value(), r.value()
+	if needCheck && typeCheckArray[op] != nil && !typeCheckArray[op](left, right) {
+		return nil, newOperandTypeError(op, true, typeName(left), typeName(right))
+	}
+	var (
+		ret any
+		err error
+	)
+	if p.decimal != nil {
+		ret, err = p.decimal.operate(op, left, right, params)
+	} else {
+		ret, err = opFuncArray[op](left, right, params)
+	}
+	if err != nil {
+		return nil, &EvalError{Op: op, Operands: []string{typeName(left), typeName(right)}, Err: err}
+	}
+	return ret, p.checkSize(ret)
+}
+
+// conditionError 三元表达式的条件不为 bool, needCheck 时为 *TypeError, 否则为 *EvalError
+func conditionError(cond any, needCheck bool) error {
+	name := typeName(cond)
+	if needCheck {
+		return &TypeError{Op: TernaryT, Operands: []string{name}, Msg: fmt.Sprintf("non-bool %s used as ? condition", name)}
+	}
+	return &EvalError{Op: TernaryT, Operands: []string{name}, Err: fmt.Errorf("execute: non-bool %s used as ? condition", name)}
+}
+
+// checkSize 检查运算、函数返回的字符串与集合是否超出 Limits
+func (p *program) checkSize(v any) error {
+	switch v := v.(type) {
+	case string:
+		if max := p.limits.MaxStringLen; max > 0 && len(v) > max {
+			return &LimitError{Err: ErrStringLimit, Limit: max}
+		}
+	case []any:
+		if max := p.limits.MaxListLen; max > 0 && len(v) > max {
+			return &LimitError{Err: ErrListLimit, Limit: max}
+		}
+	}
+	return nil
+}
+
+// panicError 将第 pc 条指令执行中的 panic 转换为 *EvalError, 记录操作符与操作数类型
+// stack 为该指令执行前的栈, 未记录时为 nil, 此时只报告位置
+func (p *program) panicError(pc int, stack []slot, r any) error {
+	var (
+		ins      = p.code[pc]
+		operands []slot
+		op       Operator
+	)
+	switch ins.op {
+	case opUnary:
+		op, operands = Operator(ins.arg), topSlots(stack, 1)
+	case opBinary:
+		op, operands = Operator(ins.arg), topSlots(stack, 2)
+	case opBinaryConst:
+		op = Operator(ins.arg)
+		if top := topSlots(stack, 1); top != nil {
+			operands = []slot{top[0], p.consts[ins.k]}
+		}
+	case opCall:
+		operands = topSlots(stack, int(ins.argc))
+	case opIndex:
+		operands = topSlots(stack, 2)
+	case opIndexConst:
+		if top := topSlots(stack, 1); top != nil {
+			operands = []slot{top[0], p.consts[ins.k]}
+		}
+	}
+	err := &EvalError{Op: op, Err: fmt.Errorf("execute: panic: %v", r)}
+	for _, operand := range operands {
+		err.Operands = append(err.Operands, typeName(operand.value()))
+	}
+	return p.errorAt(pc, err)
+}
+
+// topSlots 栈顶的 n 个元素, 不足 n 个时返回 nil
+func topSlots(stack []slot, n int) []slot {
+	if n <= 0 || len(stack) < n {
+		return nil
+	}
+	return stack[len(stack)-n:]
+}
+
+// errorAt 为第 pc 条指令的执行错误补充源码位置, 其余类型的错误包装为 *EvalError
+// 已有位置的错误(如 lambda 函数体内的执行错误)保留原位置
+func (p *program) errorAt(pc int, err error) error {
+	pos := newErrorPos(p.src, p.spans[pc])
+	switch e := err.(type) {
+	case *TypeError:
+		if e.Pos.Line == 0 {
+			e.ErrorPos = pos
+		}
+		return e
+	case *EvalError:
+		if e.Pos.Line == 0 {
+			e.ErrorPos = pos
+		}
+		return e
+	case *LimitError:
+		if e.Pos.Line == 0 {
+			e.ErrorPos = pos
+		}
+		return e
+	}
+	return &EvalError{ErrorPos: pos, Err: err}
+}
+
+// fastBinary 常见操作数类型的快速路径, 数值运算结果不装箱, 省去类型检查与 opFunc 的间接调用
+// 返回 false 时由 opFuncArray 处理
+func fastBinary(op Operator, l, r slot) (slot, bool) {
+	if l.kind == intSlot && r.kind == intSlot {
+		return fastInt(op, l.int(), r.int())
+	}
+	// float 运算直接在此计算, 少一次函数调用
+	if l.kind != refSlot && r.kind != refSlot {
+		lf, rf := l.float(), r.float()
+		switch op {
+		case Eql:
+			return slot{ref: lf == rf}, true
+		case Neq:
+			return slot{ref: lf != rf}, true
+		case Lss:
+			return slot{ref: lf < rf}, true
+		case Leq:
+			return slot{ref: lf <= rf}, true
+		case Gtr:
+			return slot{ref: lf > rf}, true
+		case Geq:
+			return slot{ref: lf >= rf}, true
+		case Add:
+			return floatSlotOf(lf + rf), true
+		case Sub:
+			return floatSlotOf(lf - rf), true
+		case Mul:
+			return floatSlotOf(lf * rf), true
+		case Div:
+			return floatSlotOf(lf / rf), true
+		case Rem:
+			return floatSlotOf(math.Mod(lf, rf)), true
+		}
+		return slot{}, false
+	}
+	if l.kind != refSlot || r.kind != refSlot {
+		return slot{}, false
+	}
+	switch lv := l.ref.(type) {
+	case bool:
+		rv, ok := r.ref.(bool)
+		if !ok {
+			return slot{}, false
+		}
+		switch op {
+		case AndAnd:
+			return slot{ref: lv && rv}, true
+		case OrOr:
+			return slot{ref: lv || rv}, true
+		case Eql:
+			return slot{ref: lv == rv}, true
+		case Neq:
+			return slot{ref: lv != rv}, true
+		}
+	case string:
+		rv, ok := r.ref.(string)
+		if !ok {
+			return slot{}, false
+		}
+		switch op {
+		case Eql:
+			return slot{ref: lv == rv}, true
+		case Neq:
+			return slot{ref: lv != rv}, true
+		}
+	}
+	return slot{}, false
+}
+
+func fastInt(op Operator, l, r int64) (slot, bool) {
+	switch op {
+	case Eql:
+		return slot{ref: l == r}, true
+	case Neq:
+		return slot{ref: l != r}, true
+	case Lss:
+		return slot{ref: l < r}, true
+	case Leq:
+		return slot{ref: l <= r}, true
+	case Gtr:
+		return slot{ref: l > r}, true
+	case Geq:
+		return slot{ref: l >= r}, true
+	case Add:
+		return intSlotOf(l + r), true
+	case Sub:
+		return intSlotOf(l - r), true
+	case Mul:
+		return intSlotOf(l * r), true
+	case Div:
+		if r != 0 {
+			return intSlotOf(l / r), true
+		}
+	case Rem:
+		if r != 0 {
+			return intSlotOf(l % r), true
+		}
+	case And:
+		return intSlotOf(l & r), true
+	case Or:
+		return intSlotOf(l | r), true
+	case Xor:
+		return intSlotOf(l ^ r), true
+	case AndNot:
+		return intSlotOf(l &^ r), true
+	}
+	return slot{}, false
+}
+
+// makeList 构造集合, 与 commaFunc 逐个连接的结果一致: 第一个元素为 []any 时其余元素追加在其后
+func makeList(items []slot) []any {
+	first := items[0].value()
+	head, ok := first.([]any)
+	if !ok {
+		head = []any{first}
+	}
+	ret := make([]any, len(head), len(head)+len(items)-1)
+	copy(ret, head)
+	for _, item := range items[1:] {
+		ret = append(ret, item.value())
+	}
+	return ret
+}
@@ -0,0 +1,279 @@
+package goexpression
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// walkNode 编译为指令之前的抽象语法树节点, 每个节点携带 opFunc 闭包, 用于对比测试与基准测试
+type walkNode struct {
+	left, right *walkNode
+	op          Operator
+	opFunc      opFunc
+	typeCheck   typeCheck
+}
+
+// walkArgs ',' 连接的参数, 与集合字面量的 []any 区分, 集合作为参数时不展开
+type walkArgs []any
+
+// newWalkNode 由 astNode 构造递归执行的节点树
+func newWalkNode(root *astNode) *walkNode {
+	if root == nil {
+		return nil
+	}
+	ret := &walkNode{left: newWalkNode(root.left), right: newWalkNode(root.right), op: root.op}
+	switch root.kind {
+	case litNode:
+		lit := root.value
+		ret.opFunc = func(l any, r any, params map[string]any) (any, error) {
+			return lit, nil
+		}
+	case varNode:
+		name := root.value.(string)
+		ret.opFunc = func(l, r any, params map[string]any) (any, error) {
+			v, ok := params[name]
+			if !ok {
+				return nil, fmt.Errorf("execute: %s param not in the passed parameter list", name)
+			}
+			return v, nil
+		}
+	case funcNode:
+		function := root.value.(ContextFunction)
+		ret.opFunc = func(left, right any, params map[string]any) (any, error) {
+			if right == nil {
+				return function(context.Background())
+			}
+			if args, ok := right.(walkArgs); ok {
+				return function(context.Background(), args...)
+			}
+			return function(context.Background(), right)
+		}
+	case commaNode:
+		ret.opFunc = func(left, right any, _ map[string]any) (any, error) {
+			if args, ok := left.(walkArgs); ok {
+				return append(args, right), nil
+			}
+			return walkArgs{left, right}, nil
+		}
+	case listNode:
+		ret.opFunc = func(left, _ any, _ map[string]any) (any, error) {
+			args, ok := left.(walkArgs)
+			if !ok {
+				args = walkArgs{left}
+			}
+			// 与 commaFunc 一致, 第一个元素为 []any 时其余元素追加在其后
+			if head, ok := args[0].([]any); ok {
+				return append(append([]any{}, head...), args[1:]...), nil
+			}
+			return []any(args), nil
+		}
+	case ternaryNode: // 由 treeWalk 按 op 选择分支
+	case indexNode:
+		ret.opFunc = func(left, right any, _ map[string]any) (any, error) {
+			return index(left, right)
+		}
+	default:
+		ret.opFunc, ret.typeCheck = opFuncArray[root.op], typeCheckArray[root.op]
+	}
+	return ret
+}
+
+// treeWalk 递归执行 walkNode
+func treeWalk(root *walkNode, params map[string]any, needCheck bool) (any, error) {
+	if root == nil {
+		return nil, nil
+	}
+	var (
+		left, right any
+		err         error
+	)
+
+	if left, err = treeWalk(root.left, params, needCheck); err != nil {
+		return nil, err
+	}
+	switch root.op {
+	case TernaryT:
+		// right 为两个分支, 只执行其中之一
+		cond, ok := left.(bool)
+		if !ok {
+			return nil, fmt.Errorf("execute: ternary condition is not bool")
+		}
+		if cond {
+			return treeWalk(root.right.left, params, needCheck)
+		}
+		return treeWalk(root.right.right, params, needCheck)
+	case AndAnd:
+		if left == false {
+			return false, nil
+		}
+	case OrOr:
+		if left == true {
+			return true, nil
+		}
+	case Coalesce:
+		if left != nil {
+			return left, nil
+		}
+	default:
+	}
+	if right, err = treeWalk(root.right, params, needCheck); err != nil {
+		return nil, err
+	}
+	if needCheck && root.typeCheck != nil {
+		if !root.typeCheck(left, right) {
+			return nil, fmt.Errorf("execute: type check error")
+		}
+	}
+	return root.opFunc(left, right, params)
+}
+
+var vmTestFunctions = map[string]Function{
+	"sum": func(params ...any) (any, error) {
+		var ret float64
+		for _, p := range params {
+			f, _ := toFloat64(p)
+			ret += f
+		}
+		return ret, nil
+	},
+	"one": func(params ...any) (any, error) {
+		return 1.0, nil
+	},
+	"null": func(params ...any) (any, error) {
+		return nil, nil
+	},
+}
+
+func TestProgram_Run(t *testing.T) {
+	params := map[string]any{"a": 1.0, "b": 2.0, "s": "go", "t": true, "f": false}
+	tests := []struct {
+		name    string
+		exp     string
+		want    any
+		wantErr bool
+	}{
+		{name: "arith", exp: "a + b * 3 - 4 / 2 ** 2 % 3", want: 6.0},
+		{name: "bit", exp: "~a & 3 | 8 ^ 1 &^ 2 << 1 >> 1", want: int64(11)},
+		{name: "unary", exp: "-a + ++b - --a", want: 2.0},
+		{name: "andAnd short", exp: "f && unknown", want: false},
+		{name: "orOr short", exp: "t || unknown", want: true},
+		{name: "andAnd", exp: "t && a < b", want: true},
+		{name: "ternary true", exp: "t ? 'x' : unknown", want: "x"},
+		{name: "ternary false", exp: "f ? unknown : 'y'", want: "y"},
+		{name: "ternary chain", exp: "a > b ? 1 : a == b ? 2 : 3", want: int64(3)},
+		{name: "string", exp: "s + '_' + s == 'go_go'", want: true},
+		{name: "in", exp: "b in [1, 2, 3] && !(s in ['a', 'b'])", want: true},
+		{name: "in empty", exp: "1 in []", want: false},
+		{name: "list", exp: "[a, b, s]", want: []any{1.0, 2.0, "go"}},
+		{name: "nested list", exp: "[[a, b], s]", want: []any{1.0, 2.0, "go"}},
+		{name: "func", exp: "sum(a, b, sum(a, b)) == 6 && one() == a", want: true},
+		{name: "func nil", exp: "null()", want: nil},
+		{name: "repeated vars", exp: "(a + b) * (a - b) + a", want: -2.0},
+		{name: "missing after coalesce", exp: "(c ?? 1) + c", wantErr: true},
+		{name: "missing param", exp: "a + c", wantErr: true},
+		{name: "type check", exp: "a + t", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExpression(tt.exp, true, vmTestFunctions)
+			if err != nil {
+				t.Fatalf("NewExpression() error = %v", err)
+			}
+			got, err := e.Execute(params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Execute() got = %v, want %v", got, tt.want)
+			}
+			walked, walkErr := treeWalk(newWalkNode(e.root), params, true)
+			if (walkErr != nil) != (err != nil) || !reflect.DeepEqual(walked, got) {
+				t.Errorf("treeWalk() got = %v, %v, vm got = %v, %v", walked, walkErr, got, err)
+			}
+		})
+	}
+}
+
+func TestProgram_CacheVars(t *testing.T) {
+	tests := []struct {
+		exp  string
+		want bool
+	}{
+		{exp: "a > b ? a * 2 : b", want: true},
+		{exp: "a = b; a", want: false},
+		{exp: "++a + a", want: false},
+		{exp: "sum(a, b) + a", want: false},
+		{exp: "map([a], x => x + a)[0] + a", want: false},
+		{exp: "a + b + c + d + e + f + g + h + i", want: false}, // 变量与栈深度之和超过 smallStackSize
+	}
+	for _, tt := range tests {
+		e, err := NewExpression(tt.exp, true, vmTestFunctions)
+		if err != nil {
+			t.Fatalf("NewExpression(%q) error = %v", tt.exp, err)
+		}
+		if e.prog.cacheVars != tt.want {
+			t.Errorf("%q cacheVars = %v, want %v", tt.exp, e.prog.cacheVars, tt.want)
+		}
+	}
+}
+
+var benchExpressions = []struct {
+	name string
+	exp  string
+}{
+	{name: "Logic", exp: "a > 10 && b < 20 || s == 'go' && !t"},
+	{name: "Arith", exp: "(a + b) * 3 - a / 2 + b % 7 - (a - b) * (a + b)"},
+	{name: "Ternary", exp: "a > b ? a * 2 : b > 100 ? b / 2 : a + b"},
+	{name: "Func", exp: "sum(a, b, 3) > 10 && 2 in [1, 2, 3]"},
+}
+
+var benchParams = map[string]any{"a": 15.0, "b": 18.0, "s": "go", "t": false}
+
+func BenchmarkExecute(b *testing.B) {
+	for _, bb := range benchExpressions {
+		e, err := NewExpression(bb.exp, true, vmTestFunctions)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(bb.name+"/VM", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := e.Execute(benchParams); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		root := newWalkNode(e.root)
+		b.Run(bb.name+"/TreeWalk", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := treeWalk(root, benchParams, true); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkExecuteParallel 多个 goroutine 同时执行同一个表达式
+func BenchmarkExecuteParallel(b *testing.B) {
+	for _, bb := range benchExpressions {
+		e, err := NewExpression(bb.exp, true, vmTestFunctions)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(bb.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := e.Execute(benchParams); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}